@@ -0,0 +1,55 @@
+package main
+
+import (
+	"sort"
+	"sync"
+
+	"sequelscope.jonnevuorela.com/types"
+)
+
+type tableKey struct {
+	database string
+	table    string
+}
+
+// activityCounter counts binlog row events per table since startup.
+type activityCounter struct {
+	mu     sync.Mutex
+	counts map[tableKey]int
+}
+
+func newActivityCounter() *activityCounter {
+	return &activityCounter{counts: make(map[tableKey]int)}
+}
+
+func (a *activityCounter) increment(database, table string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.counts[tableKey{database, table}]++
+}
+
+// snapshot returns the counters sorted by count, busiest first.
+func (a *activityCounter) snapshot() []types.TableActivity {
+	a.mu.Lock()
+	out := make([]types.TableActivity, 0, len(a.counts))
+	for k, n := range a.counts {
+		out = append(out, types.TableActivity{
+			Database: k.database,
+			Table:    k.table,
+			Count:    n,
+		})
+	}
+	a.mu.Unlock()
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		if out[i].Database != out[j].Database {
+			return out[i].Database < out[j].Database
+		}
+		return out[i].Table < out[j].Table
+	})
+	return out
+}
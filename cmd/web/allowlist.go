@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// databaseAllowlist limits which databases can be browsed. An empty list
+// allows every database.
+type databaseAllowlist map[string]bool
+
+func newDatabaseAllowlist(databases string) databaseAllowlist {
+	a := make(databaseAllowlist)
+	for _, db := range splitList(databases) {
+		a[db] = true
+	}
+	return a
+}
+
+// allow reports whether db may be browsed. Names are compared exactly, so a
+// differently cased name is refused even on servers that would accept it.
+func (a databaseAllowlist) allow(db string) bool {
+	return len(a) == 0 || a[db]
+}
+
+// databaseParams are the request parameters that name a database: db for
+// most pages and a and b for /compare.
+var databaseParams = []string{"db", "a", "b"}
+
+// restrictDatabases answers 404 for any request naming a database outside
+// the -databases allowlist, whichever handler it is for.
+func (app *application) restrictDatabases(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(app.databases) > 0 {
+			for _, param := range databaseParams {
+				db := r.FormValue(param)
+				// The query console submits "connection/database"; MySQL
+				// names can't contain a slash, so the cut is unambiguous.
+				if _, name, ok := strings.Cut(db, "/"); ok {
+					db = name
+				}
+				if db != "" && !app.databases.allow(db) {
+					app.notFound(w)
+					return
+				}
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
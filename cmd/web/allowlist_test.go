@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRestrictDatabases(t *testing.T) {
+	app := &application{databases: newDatabaseAllowlist("shop,blog")}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := app.restrictDatabases(next)
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		form   url.Values
+		want   int
+	}{
+		{"Allowed table", "GET", "/table?db=shop&table=t", nil, http.StatusOK},
+		{"Hidden table", "GET", "/table?db=hidden&table=t", nil, http.StatusNotFound},
+		{"Compare allowed", "GET", "/compare?a=shop&b=blog", nil, http.StatusOK},
+		{"Compare hidden a", "GET", "/compare?a=hidden&b=shop", nil, http.StatusNotFound},
+		{"Compare hidden b", "GET", "/compare?a=shop&b=hidden", nil, http.StatusNotFound},
+		{"Console allowed", "POST", "/query", url.Values{"db": {"main/shop"}}, http.StatusOK},
+		{"Console hidden", "POST", "/query", url.Values{"db": {"main/hidden"}}, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.form.Encode()))
+			if tt.form != nil {
+				r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, r)
+			if rr.Code != tt.want {
+				t.Errorf("status = %d; want %d", rr.Code, tt.want)
+			}
+		})
+	}
+}
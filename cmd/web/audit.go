@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// auditFlushInterval is how often buffered audit records reach the file.
+const auditFlushInterval = time.Second
+
+// auditRecord is one line of the audit log.
+type auditRecord struct {
+	Time     time.Time `json:"time"`
+	Schema   string    `json:"schema"`
+	Query    string    `json:"query"`
+	File     string    `json:"file,omitempty"`
+	Position uint32    `json:"position"`
+}
+
+// auditLog appends query events to a file as JSON lines, rotating it once it
+// grows past maxBytes.
+type auditLog struct {
+	mu       sync.Mutex
+	path     string
+	maxBytes int64
+	file     *os.File
+	w        *bufio.Writer
+	size     int64
+	done     chan struct{}
+}
+
+func openAuditLog(path string, maxBytes int64) (*auditLog, error) {
+	l := &auditLog{path: path, maxBytes: maxBytes, done: make(chan struct{})}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	go l.flushLoop()
+	return l, nil
+}
+
+func (l *auditLog) open() error {
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.file = f
+	l.w = bufio.NewWriter(f)
+	l.size = info.Size()
+	return nil
+}
+
+// rotate renames the current file with a timestamp suffix and starts a new
+// one. The caller holds l.mu.
+func (l *auditLog) rotate() error {
+	l.w.Flush()
+	l.file.Close()
+	rotated := fmt.Sprintf("%s.%s", l.path, time.Now().Format("20060102-150405"))
+	for i := 1; fileExists(rotated); i++ {
+		rotated = fmt.Sprintf("%s.%s.%d", l.path, time.Now().Format("20060102-150405"), i)
+	}
+	if err := os.Rename(l.path, rotated); err != nil {
+		// Keep appending to the current file rather than a closed one.
+		return errors.Join(err, l.open())
+	}
+	return l.open()
+}
+
+func (l *auditLog) write(rec auditRecord) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// A failed rotation doesn't lose the record; it is reported and the
+	// file keeps growing until a later rotation succeeds.
+	var rotateErr error
+	if l.maxBytes > 0 && l.size > 0 && l.size+int64(len(line)) > l.maxBytes {
+		rotateErr = l.rotate()
+	}
+	n, err := l.w.Write(line)
+	l.size += int64(n)
+	return errors.Join(rotateErr, err)
+}
+
+func (l *auditLog) flushLoop() {
+	ticker := time.NewTicker(auditFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			l.mu.Lock()
+			l.w.Flush()
+			l.mu.Unlock()
+		case <-l.done:
+			return
+		}
+	}
+}
+
+func (l *auditLog) Close() error {
+	close(l.done)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.w.Flush(); err != nil {
+		l.file.Close()
+		return err
+	}
+	return l.file.Close()
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
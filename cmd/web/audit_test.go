@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAuditLogKeepsWritingWhenRotationFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l, err := openAuditLog(path, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	if err := l.write(auditRecord{Query: "first"}); err != nil {
+		t.Fatal(err)
+	}
+	// With the file gone, renaming it for rotation fails.
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.write(auditRecord{Query: "second"}); err == nil {
+		t.Error("write after a failed rotation returned no error")
+	}
+
+	l.mu.Lock()
+	l.w.Flush()
+	l.mu.Unlock()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"second"`) {
+		t.Errorf("audit log = %q; want the second record", b)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// metadataCache holds the table names of every database, keyed by entry id,
+// so that lookups such as search don't have to query the server on each
+// request.
+type metadataCache struct {
+	mu      sync.RWMutex
+	ttl     time.Duration
+	loaded  time.Time
+	schemas map[int][]string
+}
+
+func newMetadataCache(ttl time.Duration) *metadataCache {
+	return &metadataCache{ttl: ttl}
+}
+
+func (c *metadataCache) stale() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.schemas == nil || time.Since(c.loaded) > c.ttl
+}
+
+func (c *metadataCache) snapshot() map[int][]string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.schemas
+}
+
+func (c *metadataCache) store(schemas map[int][]string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.schemas = schemas
+	c.loaded = time.Now()
+}
+
+// invalidate forces the next lookup to reload the metadata.
+func (c *metadataCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.schemas = nil
+}
+
+// schemaMetadata returns the cached entry id to table-name mapping,
+// reloading it from the server when it has expired.
+func (app *application) schemaMetadata() (map[int][]string, error) {
+	if !app.metadata.stale() {
+		return app.metadata.snapshot(), nil
+	}
+
+	entries := app.listEntries()
+	schemas := make(map[int][]string, len(entries))
+	for _, entry := range entries {
+		src, ok := app.sourceByName(entry.Connection)
+		if !ok {
+			continue
+		}
+		names, err := src.tableNames(entry.Title)
+		if err != nil {
+			return nil, err
+		}
+		schemas[entry.Id] = names
+	}
+
+	app.metadata.store(schemas)
+	return schemas, nil
+}
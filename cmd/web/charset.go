@@ -0,0 +1,60 @@
+package main
+
+import (
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+)
+
+// mysqlCharsets maps MySQL character set names to their Go decoders. MySQL's
+// latin1 is really Windows-1252.
+var mysqlCharsets = map[string]encoding.Encoding{
+	"latin1":   charmap.Windows1252,
+	"latin2":   charmap.ISO8859_2,
+	"latin5":   charmap.ISO8859_9,
+	"latin7":   charmap.ISO8859_13,
+	"cp1250":   charmap.Windows1250,
+	"cp1251":   charmap.Windows1251,
+	"cp1256":   charmap.Windows1256,
+	"cp1257":   charmap.Windows1257,
+	"cp850":    charmap.CodePage850,
+	"cp852":    charmap.CodePage852,
+	"cp866":    charmap.CodePage866,
+	"greek":    charmap.ISO8859_7,
+	"hebrew":   charmap.ISO8859_8,
+	"koi8r":    charmap.KOI8R,
+	"koi8u":    charmap.KOI8U,
+	"macroman": charmap.Macintosh,
+	"sjis":     japanese.ShiftJIS,
+	"cp932":    japanese.ShiftJIS,
+	"ujis":     japanese.EUCJP,
+	"eucjpms":  japanese.EUCJP,
+	"euckr":    korean.EUCKR,
+	"gb2312":   simplifiedchinese.GBK,
+	"gbk":      simplifiedchinese.GBK,
+	"gb18030":  simplifiedchinese.GB18030,
+	"big5":     traditionalchinese.Big5,
+}
+
+// transcodeCell converts b from the column's character set to UTF-8 when it
+// isn't valid UTF-8 already. It reports false if b still isn't valid UTF-8,
+// either because the charset is unknown or the bytes don't decode.
+func transcodeCell(b []byte, charset string) ([]byte, bool) {
+	if utf8.Valid(b) {
+		return b, true
+	}
+	enc, ok := mysqlCharsets[charset]
+	if !ok {
+		return b, false
+	}
+	out, err := enc.NewDecoder().Bytes(b)
+	if err != nil || !utf8.Valid(out) {
+		return b, false
+	}
+	return out, true
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"sequelscope.jonnevuorela.com/types"
+)
+
+// wsClient is the per-connection state kept in app.clients.
+type wsClient struct {
+	paused atomic.Bool
+
+	remoteAddr string
+	connected  time.Time
+	// sent counts messages successfully written to the client.
+	sent atomic.Int64
+
+	// writeMu serializes writes, since broadcasts come from both the binlog
+	// reader and the stats ticker and a websocket allows one writer at a time.
+	writeMu sync.Mutex
+
+	mu sync.Mutex
+	// database and table, when set, limit the events the client receives.
+	database string
+	table    string
+}
+
+// clientCount returns the number of connected websocket clients.
+func (app *application) clientCount() int {
+	app.clientsMux.RLock()
+	defer app.clientsMux.RUnlock()
+
+	return len(app.clients)
+}
+
+// writeJSON sends v to conn, one writer at a time. A positive timeout sets a
+// write deadline so a stuck connection fails instead of blocking the caller.
+func (c *wsClient) writeJSON(conn *websocket.Conn, v any, timeout time.Duration) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if timeout > 0 {
+		if err := conn.SetWriteDeadline(time.Now().Add(timeout)); err != nil {
+			return err
+		}
+	}
+	if err := conn.WriteJSON(v); err != nil {
+		return err
+	}
+	c.sent.Add(1)
+	return nil
+}
+
+// byeTimeout bounds each write of the goodbye to one client, so a stuck
+// client can't hold up shutdown.
+const byeTimeout = time.Second
+
+// sayGoodbye sends a bye message and a going-away close frame to conn.
+// Errors are ignored since the connection is being closed anyway.
+func (c *wsClient) sayGoodbye(conn *websocket.Conn, reason string) {
+	c.writeJSON(conn, map[string]any{"type": "bye", "reason": reason}, byeTimeout)
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseGoingAway, reason), time.Now().Add(byeTimeout))
+}
+
+// clientInfos describes the connected websocket clients, oldest first.
+func (app *application) clientInfos() []types.ClientInfo {
+	app.clientsMux.RLock()
+	defer app.clientsMux.RUnlock()
+
+	infos := make([]types.ClientInfo, 0, len(app.clients))
+	for _, c := range app.clients {
+		c.mu.Lock()
+		info := types.ClientInfo{
+			RemoteAddr: c.remoteAddr,
+			Connected:  c.connected,
+			Database:   c.database,
+			Table:      c.table,
+			Paused:     c.paused.Load(),
+			Sent:       c.sent.Load(),
+		}
+		c.mu.Unlock()
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Connected.Before(infos[j].Connected)
+	})
+	return infos
+}
+
+// controlMessage is sent by a client over its socket to change how it is
+// served, e.g. {"type":"pause"} or
+// {"type":"subscribe","database":"shop","table":"orders"}.
+type controlMessage struct {
+	Type     string `json:"type"`
+	Database string `json:"database"`
+	Table    string `json:"table"`
+}
+
+// handleControl applies a control message from a client.
+func (c *wsClient) handleControl(msg controlMessage) {
+	switch msg.Type {
+	case "pause":
+		c.paused.Store(true)
+	case "resume":
+		c.paused.Store(false)
+	case "subscribe":
+		c.mu.Lock()
+		c.database, c.table = msg.Database, msg.Table
+		c.mu.Unlock()
+	}
+}
+
+// wants reports whether an event message matches the client's subscription.
+// Messages without a table, such as queries, match on database alone.
+func (c *wsClient) wants(message map[string]any) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.database != "" && message["database"] != c.database {
+		return false
+	}
+	if table, ok := message["table"]; ok && c.table != "" && table != c.table {
+		return false
+	}
+	return true
+}
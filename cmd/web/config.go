@@ -0,0 +1,106 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// envPrefix is prepended to a flag name, upper-cased with dashes turned into
+// underscores, to find its environment variable (e.g. SEQUELSCOPE_RATE_LIMIT).
+const envPrefix = "SEQUELSCOPE_"
+
+// envName returns the environment variable consulted for a flag.
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// readConfigFile parses a YAML file whose keys are flag names. Scalar values
+// become a single string, lists become one value per element and, for -dsn,
+// a mapping of name: dsn becomes name=dsn values.
+func readConfigFile(path string) (map[string][]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+
+	values := make(map[string][]string, len(raw))
+	for key, v := range raw {
+		switch v := v.(type) {
+		case nil:
+		case []any:
+			for _, item := range v {
+				values[key] = append(values[key], fmt.Sprint(item))
+			}
+		case map[string]any:
+			names := make([]string, 0, len(v))
+			for name := range v {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				values[key] = append(values[key], name+"="+fmt.Sprint(v[name]))
+			}
+		default:
+			values[key] = []string{fmt.Sprint(v)}
+		}
+	}
+	return values, nil
+}
+
+// applyConfig fills in flags that weren't given on the command line, first
+// from the config file and then from the environment. Flags therefore
+// override the file, and the file overrides the environment.
+func applyConfig(fs *flag.FlagSet, path string) error {
+	var file map[string][]string
+	if path != "" {
+		var err error
+		file, err = readConfigFile(path)
+		if err != nil {
+			return err
+		}
+		for key := range file {
+			if fs.Lookup(key) == nil || key == "config" {
+				return fmt.Errorf("config file %s: unknown setting %q", path, key)
+			}
+		}
+	}
+
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || explicit[f.Name] || f.Name == "config" {
+			return
+		}
+
+		values, ok := file[f.Name]
+		if !ok {
+			env, set := os.LookupEnv(envName(f.Name))
+			if !set {
+				return
+			}
+			values = []string{env}
+		}
+
+		for _, value := range values {
+			if setErr := fs.Set(f.Name, value); setErr != nil {
+				err = fmt.Errorf("invalid value %q for %s: %w", value, f.Name, setErr)
+				return
+			}
+		}
+	})
+	return err
+}
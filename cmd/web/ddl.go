@@ -0,0 +1,66 @@
+package main
+
+import (
+	"strings"
+)
+
+// ddlStatement describes a schema-changing statement found in the binlog.
+type ddlStatement struct {
+	action string // CREATE, ALTER, DROP, RENAME or TRUNCATE
+	kind   string // "table" or "database"
+	object string
+}
+
+// parseDDL recognises table and database DDL by its leading keywords.
+func parseDDL(query string) (ddlStatement, bool) {
+	words := strings.Fields(query)
+	next := func() string {
+		if len(words) == 0 {
+			return ""
+		}
+		w := strings.ToUpper(words[0])
+		words = words[1:]
+		return w
+	}
+	skip := func(optional ...string) {
+		for _, w := range optional {
+			if len(words) > 0 && strings.EqualFold(words[0], w) {
+				words = words[1:]
+			}
+		}
+	}
+
+	var stmt ddlStatement
+	stmt.action = next()
+	switch stmt.action {
+	case "CREATE", "DROP":
+		skip("TEMPORARY")
+	case "ALTER", "RENAME":
+	case "TRUNCATE":
+		skip("TABLE")
+		stmt.kind = "table"
+	default:
+		return ddlStatement{}, false
+	}
+
+	if stmt.kind == "" {
+		switch next() {
+		case "TABLE":
+			stmt.kind = "table"
+		case "DATABASE", "SCHEMA":
+			stmt.kind = "database"
+		default:
+			return ddlStatement{}, false
+		}
+	}
+
+	skip("IF", "NOT", "EXISTS")
+	if len(words) > 0 {
+		name := words[0]
+		if i := strings.IndexAny(name, "(;"); i >= 0 {
+			name = name[:i]
+		}
+		stmt.object = strings.ReplaceAll(name, "`", "")
+	}
+	return stmt, true
+}
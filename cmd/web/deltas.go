@@ -0,0 +1,160 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"sequelscope.jonnevuorela.com/types"
+)
+
+// deltaWindow is how far back per-minute row change counts are kept for
+// /api/deltas?since=.
+const deltaWindow = 24 * time.Hour
+
+type rowDeltas struct {
+	inserts, updates, deletes int
+}
+
+func (d *rowDeltas) add(o rowDeltas) {
+	d.inserts += o.inserts
+	d.updates += o.updates
+	d.deletes += o.deletes
+}
+
+type deltaBucket struct {
+	minute time.Time
+	rowDeltas
+}
+
+// deltaCounter counts the rows inserted, updated and deleted per table since
+// startup, plus the same counts per minute over the last deltaWindow.
+type deltaCounter struct {
+	mu      sync.Mutex
+	started time.Time
+	totals  map[tableKey]*rowDeltas
+	buckets map[tableKey][]deltaBucket
+}
+
+func newDeltaCounter() *deltaCounter {
+	return &deltaCounter{
+		started: time.Now(),
+		totals:  make(map[tableKey]*rowDeltas),
+		buckets: make(map[tableKey][]deltaBucket),
+	}
+}
+
+// add records rows changed by action, as named by rowsAction.
+func (d *deltaCounter) add(database, table, action string, rows int, now time.Time) {
+	var delta rowDeltas
+	switch action {
+	case "insert":
+		delta.inserts = rows
+	case "update":
+		delta.updates = rows
+	case "delete":
+		delta.deletes = rows
+	default:
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key := tableKey{database, table}
+	if d.totals[key] == nil {
+		d.totals[key] = &rowDeltas{}
+	}
+	d.totals[key].add(delta)
+
+	minute := now.Truncate(time.Minute)
+	buckets := d.buckets[key]
+	if n := len(buckets); n > 0 && buckets[n-1].minute.Equal(minute) {
+		buckets[n-1].add(delta)
+		return
+	}
+	cutoff := minute.Add(-deltaWindow)
+	for len(buckets) > 0 && buckets[0].minute.Before(cutoff) {
+		buckets = buckets[1:]
+	}
+	d.buckets[key] = append(buckets, deltaBucket{minute, delta})
+}
+
+// since returns the counts for database, or every database if it is empty,
+// from since onwards, and the time they actually start from. A zero since
+// or one before startup gives the totals since startup. Otherwise counts go
+// by whole minutes, no further back than deltaWindow.
+func (d *deltaCounter) since(database string, since, now time.Time) ([]types.TableDeltas, time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	fromStart := since.IsZero() || !since.After(d.started)
+	if fromStart {
+		since = d.started
+	} else {
+		since = since.Truncate(time.Minute)
+		if oldest := now.Truncate(time.Minute).Add(-deltaWindow); since.Before(oldest) {
+			since = oldest
+		}
+	}
+
+	var out []types.TableDeltas
+	for key, total := range d.totals {
+		if database != "" && key.database != database {
+			continue
+		}
+		counts := *total
+		if !fromStart {
+			counts = rowDeltas{}
+			for _, b := range d.buckets[key] {
+				if !b.minute.Before(since) {
+					counts.add(b.rowDeltas)
+				}
+			}
+		}
+		if counts == (rowDeltas{}) {
+			continue
+		}
+		out = append(out, types.TableDeltas{
+			Database: key.database,
+			Table:    key.table,
+			Inserts:  counts.inserts,
+			Updates:  counts.updates,
+			Deletes:  counts.deletes,
+		})
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Database != out[j].Database {
+			return out[i].Database < out[j].Database
+		}
+		return out[i].Table < out[j].Table
+	})
+	return out, since
+}
+
+// apiDeltas returns the rows inserted, updated and deleted per table as seen
+// in the binlog, since startup or since the RFC 3339 time in since.
+func (app *application) apiDeltas(w http.ResponseWriter, r *http.Request) {
+	var since time.Time
+	if s := r.URL.Query().Get("since"); s != "" {
+		var err error
+		since, err = time.Parse(time.RFC3339, s)
+		if err != nil {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+	}
+
+	dbName := r.URL.Query().Get("db")
+	tables, from := app.deltas.since(dbName, since, time.Now())
+	if tables == nil {
+		tables = []types.TableDeltas{}
+	}
+	app.writeJSON(w, http.StatusOK, types.DeltaReport{
+		Database: dbName,
+		Since:    from,
+		Tables:   tables,
+	})
+}
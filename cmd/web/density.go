@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const densityCookie = "density"
+
+// densities are the table grid layouts a user can pick; the first is the
+// default.
+var densities = []string{"comfortable", "compact"}
+
+// density returns the grid density saved in the request's cookie.
+func density(r *http.Request) string {
+	if cookie, err := r.Cookie(densityCookie); err == nil {
+		for _, d := range densities {
+			if cookie.Value == d {
+				return d
+			}
+		}
+	}
+	return densities[0]
+}
+
+// setDensity saves the density posted by the toggle on table pages and
+// returns to the page it was posted from.
+func (app *application) setDensity(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	value := r.PostFormValue("density")
+	valid := false
+	for _, d := range densities {
+		valid = valid || value == d
+	}
+	if !valid {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     densityCookie,
+		Value:    value,
+		Path:     "/",
+		Expires:  time.Now().AddDate(1, 0, 0),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, localReferer(r, app.config.basePath+"/"), http.StatusSeeOther)
+}
+
+// localReferer returns the path and query of the request's Referer, so a
+// redirect back can't leave the site, or fallback if there is none.
+func localReferer(r *http.Request, fallback string) string {
+	ref, err := url.Parse(r.Referer())
+	if err != nil || !strings.HasPrefix(ref.Path, "/") {
+		return fallback
+	}
+	back := &url.URL{Path: ref.Path, RawQuery: ref.RawQuery}
+	return back.String()
+}
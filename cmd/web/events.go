@@ -0,0 +1,54 @@
+package main
+
+import (
+	"sync"
+
+	"sequelscope.jonnevuorela.com/types"
+)
+
+// eventLog is a fixed-size ring buffer of recently observed binlog events.
+type eventLog struct {
+	mu     sync.Mutex
+	events []types.EventSummary
+	next   int
+	full   bool
+}
+
+func newEventLog(size int) *eventLog {
+	if size < 1 {
+		size = 1
+	}
+	return &eventLog{events: make([]types.EventSummary, size)}
+}
+
+func (l *eventLog) add(ev types.EventSummary) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.events[l.next] = ev
+	l.next = (l.next + 1) % len(l.events)
+	if l.next == 0 {
+		l.full = true
+	}
+}
+
+// recent returns up to n events, newest first. n <= 0 returns everything.
+func (l *eventLog) recent(n int) []types.EventSummary {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	count := l.next
+	if l.full {
+		count = len(l.events)
+	}
+	if n <= 0 || n > count {
+		n = count
+	}
+
+	out := make([]types.EventSummary, 0, n)
+	for i := 1; i <= n; i++ {
+		idx := (l.next - i + len(l.events)) % len(l.events)
+		out = append(out, l.events[idx])
+	}
+	return out
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+)
+
+// exportFlushRows is how many rows are written between flushes of a
+// streamed export.
+const exportFlushRows = 500
+
+// tableExport streams a whole table as CSV or JSON. Rows are written as
+// they are read, so memory use doesn't grow with the table size.
+func (app *application) tableExport(w http.ResponseWriter, r *http.Request) {
+	dbName := r.URL.Query().Get("db")
+	tableName := r.URL.Query().Get("table")
+	if dbName == "" || tableName == "" {
+		app.notFound(w)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	var contentType string
+	switch format {
+	case "", "csv":
+		format = "csv"
+		contentType = "text/csv; charset=utf-8"
+	case "json":
+		contentType = "application/json"
+	default:
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	src, ok := app.source(r)
+	if !ok {
+		app.notFound(w)
+		return
+	}
+
+	stmt := fmt.Sprintf("SELECT * FROM %s.%s", quoteIdentifier(dbName), quoteIdentifier(tableName))
+	rows, err := src.db.QueryContext(r.Context(), stmt)
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": dbName + "." + tableName + "." + format,
+	}))
+	w.Header().Set("Transfer-Encoding", "chunked")
+	w.WriteHeader(http.StatusOK)
+
+	bw := bufio.NewWriter(w)
+
+	var enc rowEncoder
+	if format == "json" {
+		enc = newJSONRowEncoder(bw, columns)
+	} else {
+		enc = newCSVRowEncoder(bw, columns)
+	}
+
+	flusher, _ := w.(http.Flusher)
+	flush := func() {
+		enc.flush()
+		bw.Flush()
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	redacted := make([]bool, len(columns))
+	for i, name := range columns {
+		redacted[i] = app.redact.match(dbName, tableName, name)
+	}
+
+	values := make([]sql.RawBytes, len(columns))
+	scanArgs := make([]interface{}, len(values))
+	for i := range values {
+		scanArgs[i] = &values[i]
+	}
+
+	n := 0
+	for rows.Next() {
+		if err := rows.Scan(scanArgs...); err != nil {
+			app.errorLog.Printf("Export of %s.%s aborted: %v", dbName, tableName, err)
+			return
+		}
+		for i := range values {
+			if redacted[i] && values[i] != nil {
+				values[i] = sql.RawBytes(redactedValue)
+			}
+		}
+		if err := enc.row(values); err != nil {
+			// The client has most likely gone away.
+			app.errorLog.Printf("Export of %s.%s aborted: %v", dbName, tableName, err)
+			return
+		}
+		n++
+		if n%exportFlushRows == 0 {
+			flush()
+		}
+	}
+	if err := rows.Err(); err != nil {
+		// The status line has been sent, so all we can do is log and stop.
+		app.errorLog.Printf("Export of %s.%s aborted: %v", dbName, tableName, err)
+		return
+	}
+
+	if err := enc.close(); err != nil {
+		app.errorLog.Printf("Export of %s.%s aborted: %v", dbName, tableName, err)
+		return
+	}
+	flush()
+}
+
+// rowEncoder writes one exported row at a time.
+type rowEncoder interface {
+	row(values []sql.RawBytes) error
+	flush()
+	close() error
+}
+
+type csvRowEncoder struct {
+	w      *csv.Writer
+	record []string
+}
+
+func newCSVRowEncoder(w *bufio.Writer, columns []string) *csvRowEncoder {
+	enc := &csvRowEncoder{w: csv.NewWriter(w), record: make([]string, len(columns))}
+	enc.w.Write(columns)
+	return enc
+}
+
+// row writes values as a CSV record. CSV has no NULL, so NULLs are written
+// as empty fields.
+func (e *csvRowEncoder) row(values []sql.RawBytes) error {
+	for i, v := range values {
+		e.record[i] = formatCell(v)
+		if v == nil {
+			e.record[i] = ""
+		}
+	}
+	e.w.Write(e.record)
+	return e.w.Error()
+}
+
+func (e *csvRowEncoder) flush() {
+	e.w.Flush()
+}
+
+func (e *csvRowEncoder) close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type jsonRowEncoder struct {
+	w     *bufio.Writer
+	keys  [][]byte
+	first bool
+}
+
+func newJSONRowEncoder(w *bufio.Writer, columns []string) *jsonRowEncoder {
+	keys := make([][]byte, len(columns))
+	for i, name := range columns {
+		keys[i], _ = json.Marshal(name)
+	}
+	w.WriteString("[")
+	return &jsonRowEncoder{w: w, keys: keys, first: true}
+}
+
+// row writes values as a JSON object with keys in column order.
+func (e *jsonRowEncoder) row(values []sql.RawBytes) error {
+	if !e.first {
+		e.w.WriteString(",")
+	}
+	e.first = false
+	e.w.WriteString("\n{")
+	for i, v := range values {
+		if i > 0 {
+			e.w.WriteString(",")
+		}
+		e.w.Write(e.keys[i])
+		e.w.WriteString(":")
+		if v == nil {
+			e.w.WriteString("null")
+			continue
+		}
+		b, err := json.Marshal(formatCell(v))
+		if err != nil {
+			return err
+		}
+		e.w.Write(b)
+	}
+	_, err := e.w.WriteString("}")
+	return err
+}
+
+func (e *jsonRowEncoder) flush() {}
+
+func (e *jsonRowEncoder) close() error {
+	_, err := e.w.WriteString("\n]\n")
+	return err
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// replicationPrivilege is a global privilege the binlog watcher needs, along
+// with the other names servers use for it.
+type replicationPrivilege struct {
+	name    string
+	aliases []string
+}
+
+// requiredReplicationPrivileges are needed to read the binlog position and
+// stream events. MySQL 8 and MariaDB 10.5 renamed some of them.
+var requiredReplicationPrivileges = []replicationPrivilege{
+	{name: "REPLICATION SLAVE", aliases: []string{"REPLICATION REPLICA"}},
+	{name: "REPLICATION CLIENT", aliases: []string{"BINLOG MONITOR", "SLAVE MONITOR", "REPLICA MONITOR"}},
+}
+
+// queryGrants returns the SHOW GRANTS output for the connected user.
+func queryGrants(db *sql.DB) ([]string, error) {
+	rows, err := db.Query("SHOW GRANTS FOR CURRENT_USER()")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var grants []string
+	for rows.Next() {
+		var grant string
+		if err := rows.Scan(&grant); err != nil {
+			return nil, err
+		}
+		grants = append(grants, grant)
+	}
+	return grants, rows.Err()
+}
+
+// globalPrivileges collects the privileges granted ON *.* from SHOW GRANTS
+// lines such as "GRANT SELECT, REPLICATION SLAVE ON *.* TO `repl`@`%`".
+func globalPrivileges(grants []string) map[string]bool {
+	privs := make(map[string]bool)
+	for _, grant := range grants {
+		upper := strings.ToUpper(grant)
+		if !strings.HasPrefix(upper, "GRANT ") {
+			continue
+		}
+		list, target, ok := strings.Cut(upper[len("GRANT "):], " ON ")
+		if !ok || !strings.HasPrefix(strings.TrimSpace(target), "*.*") {
+			continue
+		}
+		for _, priv := range strings.Split(list, ",") {
+			privs[strings.Join(strings.Fields(priv), " ")] = true
+		}
+	}
+	return privs
+}
+
+// missingReplicationPrivileges returns the required privileges absent from
+// grants. ALL PRIVILEGES covers everything.
+func missingReplicationPrivileges(grants []string) []string {
+	privs := globalPrivileges(grants)
+	if privs["ALL PRIVILEGES"] || privs["ALL"] {
+		return nil
+	}
+
+	var missing []string
+	for _, req := range requiredReplicationPrivileges {
+		found := privs[req.name]
+		for _, alias := range req.aliases {
+			found = found || privs[alias]
+		}
+		if !found {
+			missing = append(missing, req.name)
+		}
+	}
+	return missing
+}
+
+// hasRoleGrants reports whether the user is granted roles, whose privileges
+// SHOW GRANTS doesn't expand.
+func hasRoleGrants(grants []string) bool {
+	for _, grant := range grants {
+		upper := strings.ToUpper(grant)
+		if strings.HasPrefix(upper, "GRANT ") && !strings.Contains(upper, " ON ") {
+			return true
+		}
+	}
+	return false
+}
+
+// checkReplicationGrants verifies the connected user may read the binlog.
+// Users holding roles are let through, since their effective privileges
+// can't be read from SHOW GRANTS alone.
+func checkReplicationGrants(db *sql.DB) error {
+	grants, err := queryGrants(db)
+	if err != nil {
+		return fmt.Errorf("SHOW GRANTS failed: %w", err)
+	}
+	if hasRoleGrants(grants) {
+		return nil
+	}
+	if missing := missingReplicationPrivileges(grants); len(missing) > 0 {
+		return fmt.Errorf("binlog user lacks the %s privilege(s); run GRANT %s ON *.* TO the binlog user",
+			strings.Join(missing, " and "), strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -1,15 +1,23 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io/fs"
 	"log"
+	"mime"
 	"net/http"
+	"net/url"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
+	mysqlDriver "github.com/go-sql-driver/mysql"
 	"github.com/justinas/alice"
 	"sequelscope.jonnevuorela.com/types"
 	"sequelscope.jonnevuorela.com/ui"
@@ -22,53 +30,386 @@ func (app *application) routes() http.Handler {
 	if err != nil {
 		log.Fatal(err)
 	}
+	etags, err := staticETags(FS)
+	if err != nil {
+		log.Fatal(err)
+	}
 	fileServer := http.FileServer(http.FS(FS))
-	mux.Handle("/static/", http.StripPrefix("/static/", fileServer))
+	mux.Handle("/static/", http.StripPrefix("/static/", cacheStatic(etags, fileServer)))
+	mux.Handle("/favicon.ico", serveStaticFile("img/favicon.ico", cacheStatic(etags, fileServer)))
 
 	mux.HandleFunc("/ws", app.handleWebSocket)
+	mux.HandleFunc("/ws/pause", app.pauseBroadcasts)
+	mux.HandleFunc("/ws/resume", app.resumeBroadcasts)
 
 	mux.HandleFunc("/", app.home)
 	mux.HandleFunc("/entry/view/", app.dbTitleView)
 	mux.HandleFunc("/entry/view/table", app.tableView)
+	mux.HandleFunc("/entry/view/table/watch", app.tableWatch)
+	mux.HandleFunc("/entry/view/row", app.rowView)
+	mux.HandleFunc("/entry/view/row/insert", app.rowInsert)
+	mux.HandleFunc("/entry/view/cell", app.cellView)
+	mux.HandleFunc("/entry/view/column", app.columnView)
+	mux.HandleFunc("/entry/view/schema", app.schemaExport)
+	mux.HandleFunc("/entry/view/routine", app.routineView)
+	mux.HandleFunc("/entry/view/export", app.tableExport)
+	mux.HandleFunc("/compare", app.compareView)
+	mux.HandleFunc("/search", app.search)
+	mux.HandleFunc("/refresh", app.refresh)
+	mux.HandleFunc("/bookmark", app.bookmark)
+	mux.HandleFunc("/density", app.setDensity)
+	mux.HandleFunc("/query", app.queryConsole)
+	mux.HandleFunc("/events", app.eventsView)
+	mux.HandleFunc("/activity", app.activityView)
+	mux.HandleFunc("/connections", app.connectionsView)
+	mux.HandleFunc("/api/status", app.apiStatus)
+	mux.HandleFunc("/api/schema", app.apiSchema)
+	mux.HandleFunc("/api/clients", app.apiClients)
+	mux.HandleFunc("/api/table-meta", app.apiTableMeta)
+	mux.HandleFunc("/api/deltas", app.apiDeltas)
+
+	var handler http.Handler = mux
+	if app.config.basePath != "" {
+		mounted := http.NewServeMux()
+		mounted.Handle(app.config.basePath+"/", http.StripPrefix(app.config.basePath, mux))
+		mounted.Handle(app.config.basePath, http.RedirectHandler(app.config.basePath+"/", http.StatusMovedPermanently))
+		handler = mounted
+	}
 
-	standard := alice.New(app.recoverPanic, app.logRequest)
-	return standard.Then(mux)
+	standard := alice.New(app.requestID, app.recoverPanic, app.logRequest, app.secureHeaders, app.rejectCrossSite, app.rateLimit, app.restrictDatabases)
+	return standard.Then(handler)
 }
+
+// homeRecentEvents is how many buffered events the home page starts with.
+const homeRecentEvents = 10
+
 func (app *application) home(w http.ResponseWriter, r *http.Request) {
-	if len(app.entries) == 0 {
+	if r.URL.Path != "/" {
+		app.notFound(w)
+		return
+	}
+
+	if len(app.listEntries()) == 0 {
 		err := app.getDatabases()
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 	}
-	data := &types.TemplateData{
-		CurrentYear: time.Now().Year(),
-		Entries:     app.entries,
+	if wantsJSON(r) {
+		schemas, err := app.schemaMetadata()
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		databases := []types.DatabaseSummary{}
+		for _, entry := range app.listEntries() {
+			databases = append(databases, types.DatabaseSummary{
+				Id:         entry.Id,
+				Title:      entry.Title,
+				Connection: entry.Connection,
+				Tables:     len(schemas[entry.Id]),
+			})
+		}
+		app.writeJSON(w, http.StatusOK, databases)
+		return
+	}
+
+	data := app.newTemplateData(w, r)
+	data.Entries = app.listEntries()
+	data.Sizes = make(map[int][]types.SizeSample, len(data.Entries))
+	for _, entry := range data.Entries {
+		data.Sizes[entry.Id] = app.sizes.get(sizeKey(entry.Connection, entry.Title))
+	}
+	data.RecentEvents = app.events.recent(homeRecentEvents)
+	var err error
+	data.Bookmarks, err = app.bookmarks.All()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
 	}
 	app.render(w, http.StatusOK, "home.tmpl", data)
 }
-func (app *application) tableView(w http.ResponseWriter, r *http.Request) {
-	dbName := r.URL.Query().Get("db")
-	tableName := r.URL.Query().Get("table")
+func (app *application) refresh(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := app.getDatabases(); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.flash(w, "Database list refreshed")
+	http.Redirect(w, r, app.config.basePath+"/", http.StatusSeeOther)
+}
 
+// bookmark adds or, with remove=1, removes a table bookmark and returns to
+// the table.
+func (app *application) bookmark(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	conn := r.PostForm.Get("conn")
+	dbName := r.PostForm.Get("db")
+	tableName := r.PostForm.Get("table")
 	if dbName == "" || tableName == "" {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	msg := "Table bookmarked"
+	if r.PostForm.Get("remove") == "1" {
+		err = app.bookmarks.Delete(conn, dbName, tableName)
+		msg = "Bookmark removed"
+	} else {
+		err = app.bookmarks.Insert(conn, dbName, tableName)
+	}
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.flash(w, msg)
+	http.Redirect(w, r, app.config.basePath+tableURL(conn, dbName, tableName), http.StatusSeeOther)
+}
+
+func (app *application) eventsView(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(w, r)
+	data.Events = app.events.recent(0)
+	app.render(w, http.StatusOK, "events.tmpl", data)
+}
+func (app *application) activityView(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(w, r)
+	data.Activity = app.activity.snapshot()
+	app.render(w, http.StatusOK, "activity.tmpl", data)
+}
+func (app *application) connectionsView(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(w, r)
+	data.ClientList = app.clientInfos()
+	app.render(w, http.StatusOK, "connections.tmpl", data)
+}
+func (app *application) apiStatus(w http.ResponseWriter, r *http.Request) {
+	app.writeJSON(w, http.StatusOK, map[string]any{
+		"watcher": app.watcherStatus.get(),
+		"paused":  app.paused.Load(),
+	})
+}
+
+func (app *application) apiClients(w http.ResponseWriter, r *http.Request) {
+	app.writeJSON(w, http.StatusOK, map[string]any{
+		"clients": app.clientCount(),
+	})
+}
+
+// apiSchema returns the full schema of a database as JSON.
+func (app *application) apiSchema(w http.ResponseWriter, r *http.Request) {
+	dbName := r.URL.Query().Get("db")
+	if dbName == "" {
+		app.notFound(w)
+		return
+	}
+
+	src, ok := app.source(r)
+	if !ok {
 		app.notFound(w)
 		return
 	}
 
-	stmt := fmt.Sprintf("SELECT * FROM %s.%s LIMIT 100", dbName, tableName)
-	rows, err := app.db.Query(stmt)
+	doc, err := src.schemaDocument(dbName)
 	if err != nil {
-		app.serverError(w, err)
+		var mysqlErr *mysqlDriver.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == erBadDB {
+			app.notFound(w)
+			return
+		}
+		app.serverError(w, r, err)
 		return
 	}
+
+	app.writeJSON(w, http.StatusOK, doc)
+}
+
+// pageLimit returns the number of rows requested by r's limit parameter,
+// defaulting to -default-limit and clamped to 1..-max-limit.
+func (app *application) pageLimit(r *http.Request) int {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil {
+		limit = app.config.defaultLimit
+	}
+	return max(1, min(limit, app.config.maxLimit))
+}
+
+// readTable loads the page of rows requested by r's db, table, conn, cols,
+// from, seek, filter_col, filter_val and display parameters. On failure it
+// has already written the error response and returns false.
+func (app *application) readTable(w http.ResponseWriter, r *http.Request) (*types.TableData, bool) {
+	dbName := r.URL.Query().Get("db")
+	tableName := r.URL.Query().Get("table")
+
+	if dbName == "" || tableName == "" {
+		app.notFound(w)
+		return nil, false
+	}
+
+	src, ok := app.source(r)
+	if !ok {
+		app.notFound(w)
+		return nil, false
+	}
+
+	display := r.URL.Query().Get("display")
+	switch display {
+	case "", displayUTF8, displayHex, displayBase64:
+	default:
+		app.clientError(w, http.StatusBadRequest)
+		return nil, false
+	}
+	displayCol := r.URL.Query().Get("display_col")
+	from := r.URL.Query().Get("from")
+
+	schema, err := src.tableColumns(dbName, tableName)
+	if err != nil {
+		var mysqlErr *mysqlDriver.MySQLError
+		if errors.As(err, &mysqlErr) && (mysqlErr.Number == erBadDB || mysqlErr.Number == erNoSuchTable) {
+			app.notFound(w)
+			return nil, false
+		}
+		app.serverError(w, r, err)
+		return nil, false
+	}
+	charsets, err := src.columnCharsets(dbName, tableName)
+	if err != nil {
+		app.serverError(w, r, err)
+		return nil, false
+	}
+	keys := primaryKeys(schema)
+
+	// from continues after a row; seek starts at the first row whose key is
+	// at least the one given.
+	cursor, op := from, ">"
+	if seek := r.URL.Query().Get("seek"); seek != "" {
+		if from != "" {
+			app.clientError(w, http.StatusBadRequest)
+			return nil, false
+		}
+		cursor, op = seek, ">="
+	}
+	if cursor != "" && len(keys) == 0 {
+		app.clientError(w, http.StatusBadRequest)
+		return nil, false
+	}
+	selected, ok := selectedColumns(r.URL.Query()["cols"], schema)
+	if !ok {
+		app.clientError(w, http.StatusBadRequest)
+		return nil, false
+	}
+	// ENUM and SET columns can be filtered on one of their allowed values.
+	enumOptions := make(map[string][]string)
+	for _, col := range schema {
+		if values := enumValues(col.Type); values != nil {
+			enumOptions[col.Field] = values
+		}
+	}
+	filterCol := r.URL.Query().Get("filter_col")
+	filterVal := r.URL.Query().Get("filter_val")
+	if filterVal == "" {
+		filterCol = ""
+	}
+	if filterCol != "" && !slices.Contains(enumOptions[filterCol], filterVal) {
+		app.clientError(w, http.StatusBadRequest)
+		return nil, false
+	}
+
+	read := selected
+	if read == nil {
+		for _, col := range schema {
+			read = append(read, col.Field)
+		}
+	}
+	// Key columns are read as stored since their values address rows, as
+	// are columns the display parameter asks to see byte for byte.
+	raw := slices.Clone(keys)
+	switch {
+	case display == "":
+	case displayCol == "":
+		raw = read
+	default:
+		raw = append(raw, displayCol)
+	}
+	selectList := selectExpressions(read, schema, src.version, raw)
+
+	// Tables with a primary key are read in key order so the last key on
+	// the page can be used as a cursor for the next one. Composite keys are
+	// compared as a row value.
+	stmt := fmt.Sprintf("SELECT %s FROM %s.%s", selectList, quoteIdentifier(dbName), quoteIdentifier(tableName))
+	var (
+		conds []string
+		args  []any
+	)
+	if filterCol != "" {
+		if baseType(columnTypes([]string{filterCol}, schema)[0]) == "set" {
+			conds = append(conds, fmt.Sprintf("FIND_IN_SET(?, %s)", quoteIdentifier(filterCol)))
+		} else {
+			conds = append(conds, quoteIdentifier(filterCol)+" = ?")
+		}
+		args = append(args, filterVal)
+	}
+	quotedKeys := make([]string, len(keys))
+	for i, key := range keys {
+		quotedKeys[i] = quoteIdentifier(key)
+	}
+	if cursor != "" {
+		cursorValues, err := decodeRowKey(cursor, len(keys))
+		if err != nil {
+			app.clientError(w, http.StatusBadRequest)
+			return nil, false
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(keys)), ", ")
+		conds = append(conds, fmt.Sprintf("(%s) %s (%s)", strings.Join(quotedKeys, ", "), op, placeholders))
+		args = append(args, keyArgs(cursorValues)...)
+	}
+	if len(conds) > 0 {
+		stmt += " WHERE " + strings.Join(conds, " AND ")
+	}
+	if len(keys) > 0 {
+		stmt += " ORDER BY " + strings.Join(quotedKeys, ", ")
+	}
+	limit := app.pageLimit(r)
+	stmt += fmt.Sprintf(" LIMIT %d", limit)
+
+	var plan []map[string]string
+	if r.URL.Query().Get("explain") == "1" {
+		ctx, cancel := context.WithTimeout(r.Context(), explainTimeout)
+		plan, err = src.explain(ctx, "", stmt, args...)
+		cancel()
+		if err != nil {
+			app.serverError(w, r, err)
+			return nil, false
+		}
+	}
+
+	rows, err := src.db.Query(stmt, args...)
+	if err != nil {
+		app.serverError(w, r, err)
+		return nil, false
+	}
 	defer rows.Close()
 
 	columns, err := rows.Columns()
 	if err != nil {
-		app.serverError(w, err)
-		return
+		app.serverError(w, r, err)
+		return nil, false
 	}
 
 	// Prepare data container
@@ -79,140 +420,465 @@ func (app *application) tableView(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var tableData types.TableData
+	tableData.Connection = src.name
+	tableData.Database = dbName
+	tableData.Table = tableName
 	tableData.Columns = columns
+	tableData.DisplayMode = display
+	tableData.DisplayColumn = displayCol
+	tableData.Linkify = r.URL.Query().Get("links") == "1"
+	tableData.PrimaryKey = keys
+	tableData.ColumnTypes = columnTypes(columns, schema)
+	for _, col := range schema {
+		tableData.AllColumns = append(tableData.AllColumns, col.Field)
+	}
+	if selected != nil {
+		tableData.SelectedColumns = strings.Join(selected, ",")
+	}
+	if len(enumOptions) > 0 {
+		tableData.EnumOptions = enumOptions
+	}
+	tableData.FilterColumn = filterCol
+	tableData.FilterValue = filterVal
+	if app.config.displayTZ != nil {
+		tableData.TimeZone = app.config.displayTZ.String()
+	}
+	if plan != nil {
+		tableData.Statement = stmt
+		tableData.Plan = plan
+		for _, step := range plan {
+			if step["type"] == "ALL" {
+				tableData.FullScan = true
+			}
+		}
+	}
 
+	var lastKey string
 	for rows.Next() {
 		err = rows.Scan(scanArgs...)
 		if err != nil {
-			app.serverError(w, err)
-			return
+			app.serverError(w, r, err)
+			return nil, false
 		}
 
 		row := make(map[string]string)
+		var truncated map[string]bool
+		keyValues := make([]string, 0, len(keys))
 		for i, col := range values {
+			if slices.Contains(keys, columns[i]) {
+				keyValues = append(keyValues, string(col))
+			}
 			if col == nil {
 				row[columns[i]] = "NULL"
+			} else if app.redact.match(dbName, tableName, columns[i]) {
+				row[columns[i]] = redactedValue
+			} else if displayCol == "" || displayCol == columns[i] {
+				row[columns[i]] = app.tableCell(&tableData, columns[i], col, charsets, display)
 			} else {
-				row[columns[i]] = string(col)
+				row[columns[i]] = app.tableCell(&tableData, columns[i], col, charsets, displayUTF8)
+			}
+			if tz := app.config.displayTZ; tz != nil && col != nil && isDateTime(tableData.ColumnTypes[i]) &&
+				(display == "" || display == displayUTF8 || (displayCol != "" && displayCol != columns[i])) {
+				row[columns[i]] = convertDateTime(row[columns[i]], src.loc, tz)
+			}
+			if cut, ok := truncateCell(row[columns[i]], app.config.maxCellBytes); ok {
+				row[columns[i]] = cut
+				if truncated == nil {
+					truncated = make(map[string]bool)
+				}
+				truncated[columns[i]] = true
 			}
 		}
 		tableData.Rows = append(tableData.Rows, row)
+		tableData.Truncated = append(tableData.Truncated, truncated)
+		if len(keys) > 0 {
+			lastKey = encodeRowKey(keyValues)
+			tableData.RowKeys = append(tableData.RowKeys, lastKey)
+		}
 	}
 
 	if err = rows.Err(); err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
+		return nil, false
+	}
+
+	tableData.TotalRows, tableData.TotalApprox, err = src.rowCount(dbName, tableName, app.config.exactCountThreshold)
+	if err != nil {
+		app.serverError(w, r, err)
+		return nil, false
+	}
+
+	tableData.Limit = limit
+	if len(keys) > 0 && len(tableData.Rows) == limit {
+		tableData.NextCursor = lastKey
+	}
+
+	return &tableData, true
+}
+
+// tableWatch shows a table's rows and patches them live as its binlog
+// events arrive.
+func (app *application) tableWatch(w http.ResponseWriter, r *http.Request) {
+	tableData, ok := app.readTable(w, r)
+	if !ok {
+		return
+	}
+
+	data := app.newTemplateData(w, r)
+	data.Entry = &types.Entry{
+		Title:      tableData.Database,
+		Connection: tableData.Connection,
+		Tables:     []types.Table{{TableName: tableData.Table}},
+	}
+	data.TableData = tableData
+	app.render(w, http.StatusOK, "watch.tmpl", data)
+}
+
+func (app *application) tableView(w http.ResponseWriter, r *http.Request) {
+	tableData, ok := app.readTable(w, r)
+	if !ok {
+		return
+	}
+
+	// "Load more" fetches just the next rows, rendered like the first page,
+	// with the cursor after them in a header.
+	if r.URL.Query().Get("format") == "rows" {
+		if tableData.NextCursor != "" {
+			w.Header().Set("X-Next-Cursor", url.PathEscape(tableData.NextCursor))
+		}
+		app.renderTemplate(w, http.StatusOK, "table.tmpl", "datarows", tableData)
+		return
+	}
+	if wantsJSON(r) {
+		app.writeJSON(w, http.StatusOK, tableData)
 		return
 	}
 
-	data := app.newTemplateData(r)
+	data := app.newTemplateData(w, r)
 	data.Entry = &types.Entry{
-		Title: dbName,
+		Title:      tableData.Database,
+		Connection: tableData.Connection,
 		Tables: []types.Table{
 			{
-				TableName: tableName,
+				TableName: tableData.Table,
 			},
 		},
 	}
-	data.TableData = &tableData
+	data.TableData = tableData
+	var err error
+	data.Bookmarked, err = app.bookmarks.Exists(tableData.Connection, tableData.Database, tableData.Table)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
 
 	app.render(w, http.StatusOK, "table.tmpl", data)
 }
-func (app *application) dbTitleView(writer http.ResponseWriter, request *http.Request) {
-	id := strings.TrimPrefix(request.URL.Path, "/entry/view/")
-	idNum, err := strconv.Atoi(id)
+
+// tableCell formats one table value. In text mode, values that aren't valid
+// UTF-8 are transcoded from the column's character set, and the column is
+// flagged if that fails.
+func (app *application) tableCell(td *types.TableData, column string, b []byte, charsets map[string]string, display string) string {
+	if display != "" && display != displayUTF8 {
+		return formatCellAs(b, display)
+	}
+	charset, isText := charsets[column]
+	if !isText || charset == "binary" {
+		return formatCell(b)
+	}
+	b, ok := transcodeCell(b, charset)
+	if !ok {
+		if td.InvalidUTF8 == nil {
+			td.InvalidUTF8 = make(map[string]bool)
+		}
+		td.InvalidUTF8[column] = true
+	}
+	return formatCell(b)
+}
+
+func (app *application) rowView(w http.ResponseWriter, r *http.Request) {
+	dbName := r.URL.Query().Get("db")
+	tableName := r.URL.Query().Get("table")
+	pk := r.URL.Query().Get("pk")
+
+	if dbName == "" || tableName == "" || pk == "" {
+		app.notFound(w)
+		return
+	}
+
+	src, ok := app.source(r)
+	if !ok {
+		app.notFound(w)
+		return
+	}
+
+	row, err := src.fetchRow(dbName, tableName, pk)
 	if err != nil {
-		app.notFound(writer)
+		app.rowError(w, r, err)
 		return
 	}
 
-	if idNum >= len(app.entries) {
-		app.notFound(writer)
+	var fields []types.RowField
+	for i, col := range row.values {
+		field := types.RowField{Name: row.names[i]}
+		if col == nil {
+			field.Null = true
+		} else if app.redact.match(dbName, tableName, row.names[i]) {
+			field.Value = redactedValue
+		} else {
+			field.Value = formatCell(col)
+		}
+		fields = append(fields, field)
+	}
+
+	data := app.newTemplateData(w, r)
+	data.Entry = &types.Entry{
+		Title:      dbName,
+		Connection: src.name,
+		Tables: []types.Table{
+			{
+				TableName: tableName,
+			},
+		},
+	}
+	data.Row = fields
+	data.RowKey = pk
+	app.render(w, http.StatusOK, "row.tmpl", data)
+}
+
+// rowInsert returns an INSERT statement reproducing a single row.
+func (app *application) rowInsert(w http.ResponseWriter, r *http.Request) {
+	dbName := r.URL.Query().Get("db")
+	tableName := r.URL.Query().Get("table")
+	pk := r.URL.Query().Get("pk")
+
+	if dbName == "" || tableName == "" || pk == "" {
+		app.notFound(w)
 		return
 	}
 
-	entry := app.entries[idNum]
-	entry.Tables = []types.Table{}
+	src, ok := app.source(r)
+	if !ok {
+		app.notFound(w)
+		return
+	}
 
-	stmt := "SHOW TABLES FROM " + entry.Title
-	rows, err := app.db.Query(stmt)
+	row, err := src.fetchRow(dbName, tableName, pk)
 	if err != nil {
-		app.serverError(writer, err)
+		app.rowError(w, r, err)
 		return
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var tableName string
-		if err := rows.Scan(&tableName); err != nil {
-			app.serverError(writer, err)
-			return
-		}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(insertStatement(dbName, tableName, row, app.redact)))
+}
+
+// rowError maps fetchRow errors to responses.
+func (app *application) rowError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, errNoRecord):
+		app.notFound(w)
+	case errors.Is(err, errNoPrimaryKey):
+		http.Error(w, "This table has no primary key, so its rows can't be addressed individually.", http.StatusBadRequest)
+	case errors.Is(err, errBadRowKey):
+		app.clientError(w, http.StatusBadRequest)
+	default:
+		app.serverError(w, r, err)
+	}
+}
+func (app *application) schemaExport(w http.ResponseWriter, r *http.Request) {
+	dbName := r.URL.Query().Get("db")
+	if dbName == "" {
+		app.notFound(w)
+		return
+	}
+
+	src, ok := app.source(r)
+	if !ok {
+		app.notFound(w)
+		return
+	}
+
+	tableNames, err := src.tableNames(dbName)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	// A missing database lists no tables, and neither export is worth a file.
+	if len(tableNames) == 0 {
+		app.notFound(w)
+		return
+	}
 
-		// Get columns for each table
-		stmt := fmt.Sprintf("SHOW COLUMNS FROM %s.%s", entry.Title, tableName)
-		colRows, err := app.db.Query(stmt)
+	deps, err := src.foreignKeyDependencies(dbName)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "-- Schema export of %s generated by SequelScope\n\n", dbName)
+	for _, tableName := range orderByDependencies(tableNames, deps) {
+		ddl, err := src.showCreateTable(dbName, tableName)
 		if err != nil {
-			app.serverError(writer, err)
+			app.serverError(w, r, err)
 			return
 		}
-		defer colRows.Close()
-
-		var columns []types.Column
-		for colRows.Next() {
-			var col types.Column
-			if err := colRows.Scan(
-				&col.Field,
-				&col.Type,
-				&col.Null,
-				&col.Key,
-				&col.Default,
-				&col.Extra,
-			); err != nil {
-				app.serverError(writer, err)
-				return
-			}
-			columns = append(columns, col)
-		}
+		fmt.Fprintf(&buf, "%s;\n\n", ddl)
+	}
 
-		// Get count of rows
-		stmt = fmt.Sprintf("SELECT COUNT(*) FROM %s.%s", entry.Title, tableName)
-		var count int
-		if err := app.db.QueryRow(stmt).Scan(&count); err != nil {
-			app.serverError(writer, err)
-			return
-		}
+	w.Header().Set("Content-Type", "application/sql; charset=utf-8")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": dbName + ".sql",
+	}))
+	buf.WriteTo(w)
+}
+func (app *application) compareView(w http.ResponseWriter, r *http.Request) {
+	dbA := r.URL.Query().Get("a")
+	dbB := r.URL.Query().Get("b")
+
+	data := app.newTemplateData(w, r)
+	data.Entries = app.listEntries()
+
+	if dbA == "" || dbB == "" {
+		app.render(w, http.StatusOK, "compare.tmpl", data)
+		return
+	}
+
+	// Each side may live on a different connection.
+	srcA, okA := app.sourceByName(r.URL.Query().Get("conn_a"))
+	srcB, okB := app.sourceByName(r.URL.Query().Get("conn_b"))
+	if !okA || !okB {
+		app.notFound(w)
+		return
+	}
 
-		// Get latest entry
-		var latest types.LatestRow
-		var titleBytes []byte
-		stmt = fmt.Sprintf("SELECT id, title FROM %s.%s ORDER BY id DESC LIMIT 1", entry.Title, tableName)
-		app.db.QueryRow(stmt).Scan(&latest.Id, &titleBytes)
+	tablesA, err := srcA.databaseTables(dbA, "")
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+	tablesB, err := srcB.databaseTables(dbB, "")
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	data.Diff = diffSchemas(dbA, dbB, tablesA, tablesB)
+	app.render(w, http.StatusOK, "compare.tmpl", data)
+}
+func (app *application) search(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		app.writeJSON(w, http.StatusOK, []searchResult{})
+		return
+	}
 
-		latest.Title = string(titleBytes)
+	schemas, err := app.schemaMetadata()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
 
-		table := types.Table{
-			TableName:   tableName,
-			Columns:     columns,
-			EntryCount:  count,
-			LatestEntry: latest,
+	results := searchMetadata(query, app.listEntries(), schemas)
+	if results == nil {
+		results = []searchResult{}
+	}
+	for i := range results {
+		results[i].URL = app.config.basePath + results[i].URL
+	}
+	app.writeJSON(w, http.StatusOK, results)
+}
+func (app *application) dbTitleView(writer http.ResponseWriter, request *http.Request) {
+	id := strings.TrimPrefix(request.URL.Path, "/entry/view/")
+	idNum, err := strconv.Atoi(id)
+	if err != nil {
+		app.notFound(writer)
+		return
+	}
+
+	shared, ok := app.entry(idNum)
+	if !ok {
+		app.notFound(writer)
+		return
+	}
+
+	src, ok := app.sourceByName(shared.Connection)
+	if !ok {
+		app.notFound(writer)
+		return
+	}
+
+	// Work on a copy so concurrent requests don't share the Tables slice.
+	entry := &types.Entry{
+		Id:         shared.Id,
+		Title:      shared.Title,
+		Connection: shared.Connection,
+		Created:    shared.Created,
+		Tables:     []types.Table{},
+	}
+
+	// Only the table list is read here. Columns, counts and indexes are
+	// fetched from /api/table-meta when a table is expanded.
+	filter := strings.TrimSpace(request.URL.Query().Get("filter"))
+	tables, err := src.tableList(entry.Title, filter)
+	if err != nil {
+		app.serverError(writer, request, err)
+		return
+	}
+
+	// One information_schema query covers the whole database, so this stays
+	// cheap even without expanding any table.
+	statuses, err := src.tableStatuses(entry.Title)
+	if err != nil {
+		app.serverError(writer, request, err)
+		return
+	}
+	for i := range tables {
+		st, ok := statuses[tables[i].TableName]
+		if !ok {
+			continue
+		}
+		tables[i].AutoIncrement = st.autoIncrement
+		tables[i].AutoIncrementPct = st.autoIncrementPct
+		tables[i].AutoIncrementWarn = st.autoIncrement > 0 && st.autoIncrementPct >= app.config.autoIncrementWarn
+		tables[i].UpdateTime = st.updateTime
+		if tz := app.config.displayTZ; tz != nil && !st.updateTime.IsZero() {
+			tables[i].UpdateTime = st.updateTime.In(tz)
 		}
-		entry.Tables = append(entry.Tables, table)
 	}
+	entry.Tables = append(entry.Tables, tables...)
 
-	data := app.newTemplateData(request)
+	routines, err := src.routines(entry.Title)
+	if err != nil {
+		app.serverError(writer, request, err)
+		return
+	}
+	triggers, err := src.triggers(entry.Title)
+	if err != nil {
+		app.serverError(writer, request, err)
+		return
+	}
+
+	data := app.newTemplateData(writer, request)
 	data.Entry = entry
+	data.Filter = filter
+	data.Routines = routines
+	data.Triggers = triggers
+	data.Breadcrumbs = app.breadcrumbs(entry.Connection, entry.Title, "")
 	app.render(writer, http.StatusOK, "view.tmpl", data)
 }
 
 func (app *application) handleWebSocket(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+	conn, err := app.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		app.errorLog.Printf("Websocket upgrade failed: %v", err)
 		return
 	}
 
+	client := &wsClient{remoteAddr: r.RemoteAddr, connected: time.Now()}
 	app.clientsMux.Lock()
-	app.clients[conn] = true
+	app.clients[conn] = client
 	app.clientsMux.Unlock()
 
 	defer func() {
@@ -222,10 +888,16 @@ func (app *application) handleWebSocket(w http.ResponseWriter, r *http.Request)
 		app.clientsMux.Unlock()
 	}()
 
-	// keeping connection
+	// keeping connection and reading control messages
 	for {
-		if _, _, err := conn.ReadMessage(); err != nil {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
 			break
 		}
+		var msg controlMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			continue
+		}
+		client.handleControl(msg)
 	}
 }
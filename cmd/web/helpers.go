@@ -2,21 +2,48 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"html/template"
 	"io/fs"
-	"log"
+	"mime"
 	"net/http"
+	"net/url"
+	"os"
 	"path/filepath"
+	"regexp"
 	"runtime/debug"
+	"slices"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"sequelscope.jonnevuorela.com/types"
-	"sequelscope.jonnevuorela.com/ui"
 )
 
 func (app *application) render(w http.ResponseWriter, status int, page string, data *types.TemplateData) {
-	ts, ok := app.templateCache[page]
+	app.renderTemplate(w, status, page, "base", data)
+}
+
+// renderTemplate executes the template called name from page's set, e.g. a
+// partial on its own.
+func (app *application) renderTemplate(w http.ResponseWriter, status int, page, name string, data any) {
+	cache := app.templateCache
+	if app.config.dev {
+		// Re-parse from disk so template edits show up without a restart.
+		var err error
+		cache, err = newTemplateCache(os.DirFS(devUIDir), app.config.basePath)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	ts, ok := cache[page]
 	if !ok {
 		err := fmt.Errorf("the template %s does not exist", page)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -24,7 +51,7 @@ func (app *application) render(w http.ResponseWriter, status int, page string, d
 	}
 
 	buf := new(bytes.Buffer)
-	err := ts.ExecuteTemplate(buf, "base", data)
+	err := ts.ExecuteTemplate(buf, name, data)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -34,44 +61,361 @@ func (app *application) render(w http.ResponseWriter, status int, page string, d
 	buf.WriteTo(w)
 }
 
+// listEntries returns the current database list. The slice is replaced
+// wholesale on refresh and must not be modified by callers.
+func (app *application) listEntries() []*types.Entry {
+	app.entriesMux.RLock()
+	defer app.entriesMux.RUnlock()
+
+	return app.entries
+}
+
+func (app *application) setEntries(entries []*types.Entry) {
+	app.entriesMux.Lock()
+	defer app.entriesMux.Unlock()
+
+	app.entries = entries
+}
+
+// entry returns the database with the given id.
+func (app *application) entry(id int) (*types.Entry, bool) {
+	entries := app.listEntries()
+	if id < 0 || id >= len(entries) {
+		return nil, false
+	}
+	return entries[id], true
+}
+
+// entryByName finds the database named db on the named connection.
+func (app *application) entryByName(conn, db string) (*types.Entry, bool) {
+	src, ok := app.sourceByName(conn)
+	if !ok {
+		return nil, false
+	}
+	for _, e := range app.listEntries() {
+		if e.Connection == src.name && e.Title == db {
+			return e, true
+		}
+	}
+	return nil, false
+}
+
+// breadcrumbs builds the Home / database / table trail for a page showing
+// db and table, either of which may be empty.
+func (app *application) breadcrumbs(conn, db, table string) []types.Crumb {
+	crumbs := []types.Crumb{{Label: "Home", URL: "/"}}
+	if db == "" {
+		return crumbs
+	}
+
+	dbCrumb := types.Crumb{Label: db}
+	if e, ok := app.entryByName(conn, db); ok {
+		dbCrumb.URL = fmt.Sprintf("/entry/view/%d", e.Id)
+	}
+	crumbs = append(crumbs, dbCrumb)
+
+	if table != "" {
+		crumbs = append(crumbs, types.Crumb{Label: table, URL: tableURL(conn, db, table)})
+	}
+	return crumbs
+}
+
+// getDatabases reloads the database list from every connection and
+// replaces the entry list with it.
 func (app *application) getDatabases() error {
-	id := 0
+	var entries []*types.Entry
+	for _, src := range app.sources {
+		names, err := src.databases()
+		if err != nil {
+			return fmt.Errorf("connection %s: %w", src.name, err)
+		}
+		for _, dbName := range names {
+			if !app.databases.allow(dbName) {
+				continue
+			}
+			entries = append(entries, &types.Entry{
+				Id:         len(entries),
+				Title:      dbName,
+				Connection: src.name,
+			})
+		}
+	}
 
-	rows, err := app.db.Query("SHOW DATABASES")
+	app.setEntries(entries)
+
+	app.metadata.invalidate()
+	return nil
+
+}
+
+// explainTimeout bounds how long an EXPLAIN may run against the source server.
+const explainTimeout = 2 * time.Second
+
+// isReadStatement reports whether the query is a plain SELECT.
+func isReadStatement(query string) bool {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return false
+	}
+	return strings.EqualFold(fields[0], "SELECT")
+}
+
+// explain runs EXPLAIN for the query with schema as the default database and
+// returns the plan rows keyed by column name.
+func (s *source) explain(ctx context.Context, schema, query string, args ...any) ([]map[string]string, error) {
+	conn, err := s.db.Conn(ctx)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
+	}
+	defer conn.Close()
+
+	if schema != "" {
+		if _, err := conn.ExecContext(ctx, "USE "+quoteIdentifier(schema)); err != nil {
+			return nil, err
+		}
 	}
 
+	rows, err := conn.QueryContext(ctx, "EXPLAIN "+query, args...)
+	if err != nil {
+		return nil, err
+	}
 	defer rows.Close()
 
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]sql.RawBytes, len(columns))
+	scanArgs := make([]interface{}, len(values))
+	for i := range values {
+		scanArgs[i] = &values[i]
+	}
+
+	var plan []map[string]string
 	for rows.Next() {
-		var dbName string
-		if err := rows.Scan(&dbName); err != nil {
-			log.Fatal(err)
+		if err := rows.Scan(scanArgs...); err != nil {
+			return nil, err
 		}
-		db := &types.Entry{
-			Title: dbName,
-			Id:    id,
+		row := make(map[string]string)
+		for i, col := range values {
+			if col == nil {
+				row[columns[i]] = "NULL"
+			} else {
+				row[columns[i]] = string(col)
+			}
 		}
-		app.entries = append(app.entries, db)
-		id++
+		plan = append(plan, row)
 	}
 
-	if err := rows.Err(); err != nil {
-		log.Fatal(err)
-		return err
+	return plan, rows.Err()
+}
+
+// binaryPreviewBytes is how many leading bytes of a binary value are shown
+// as hex.
+const binaryPreviewBytes = 16
+
+// formatCell converts a scanned column value to display text. Values that
+// aren't valid UTF-8 are replaced with a size placeholder and a hex preview.
+func formatCell(b []byte) string {
+	if utf8.Valid(b) {
+		return string(b)
 	}
 
-	return nil
+	preview := b
+	if len(preview) > binaryPreviewBytes {
+		preview = preview[:binaryPreviewBytes]
+	}
+	s := fmt.Sprintf("<binary: %d bytes> %x", len(b), preview)
+	if len(b) > binaryPreviewBytes {
+		s += "..."
+	}
+	return s
+}
 
+// truncateCell cuts s to at most max bytes on a rune boundary and appends an
+// ellipsis. It reports whether s was cut; max <= 0 disables truncation.
+func truncateCell(s string, max int) (string, bool) {
+	if max <= 0 || len(s) <= max {
+		return s, false
+	}
+	cut := max
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + "…", true
 }
 
-var functions = template.FuncMap{
-	"truncate": func(s string, n int) string {
-		if len(s) <= n {
+// Cell display modes accepted by the table view's display parameter.
+const (
+	displayUTF8   = "utf8"
+	displayHex    = "hex"
+	displayBase64 = "base64"
+)
+
+// formatCellAs converts a value using the given display mode, falling back to
+// formatCell for the default UTF-8 mode.
+func formatCellAs(b []byte, mode string) string {
+	switch mode {
+	case displayHex:
+		return hex.EncodeToString(b)
+	case displayBase64:
+		return base64.StdEncoding.EncodeToString(b)
+	default:
+		return formatCell(b)
+	}
+}
+
+// quoteIdentifier wraps a schema, table or column name in backticks.
+func quoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+var urlPattern = regexp.MustCompile(`https?://[^\s<>"']+`)
+
+// linkify escapes s and wraps well-formed http(s) URLs in anchor tags.
+func linkify(s string) template.HTML {
+	var b strings.Builder
+	last := 0
+	for _, loc := range urlPattern.FindAllStringIndex(s, -1) {
+		raw := s[loc[0]:loc[1]]
+		u, err := url.Parse(raw)
+		if err != nil || u.Host == "" {
+			continue
+		}
+		b.WriteString(template.HTMLEscapeString(s[last:loc[0]]))
+		fmt.Fprintf(&b, `<a href="%s" rel="noopener noreferrer" target="_blank">%s</a>`,
+			template.HTMLEscapeString(u.String()), template.HTMLEscapeString(raw))
+		last = loc[1]
+	}
+	b.WriteString(template.HTMLEscapeString(s[last:]))
+	return template.HTML(b.String())
+}
+
+// baseType returns the lowercased type name without length or modifiers,
+// e.g. "int" for "int(10) unsigned".
+func baseType(columnType string) string {
+	t := strings.ToLower(strings.TrimSpace(columnType))
+	if i := strings.IndexAny(t, "( "); i >= 0 {
+		t = t[:i]
+	}
+	return t
+}
+
+func isNumeric(columnType string) bool {
+	switch baseType(columnType) {
+	case "tinyint", "smallint", "mediumint", "int", "integer", "bigint",
+		"decimal", "numeric", "float", "double", "real":
+		return true
+	}
+	return false
+}
+
+func isTemporal(columnType string) bool {
+	switch baseType(columnType) {
+	case "date", "datetime", "timestamp":
+		return true
+	}
+	return false
+}
+
+// formatNumber inserts thousands separators into the integer part of a
+// numeric string. Non-numeric values are returned unchanged.
+func formatNumber(s string) string {
+	sign := ""
+	digits := s
+	if strings.HasPrefix(digits, "-") {
+		sign, digits = "-", digits[1:]
+	}
+	frac := ""
+	if i := strings.IndexByte(digits, '.'); i >= 0 {
+		digits, frac = digits[:i], digits[i:]
+	}
+	if digits == "" {
+		return s
+	}
+	for _, r := range digits {
+		if r < '0' || r > '9' {
 			return s
 		}
-		return s[:n] + "..."
+	}
+
+	var b strings.Builder
+	for i, r := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(r)
+	}
+	return sign + b.String() + frac
+}
+
+// formatDate renders MySQL DATE/DATETIME text in a friendlier layout,
+// returning the input unchanged when it doesn't parse.
+func formatDate(s string) string {
+	if t, err := time.Parse("2006-01-02 15:04:05", s); err == nil {
+		return t.Format("02 Jan 2006 15:04:05")
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t.Format("02 Jan 2006")
+	}
+	return s
+}
+
+// invalidDate describes MySQL date text that no calendar date matches:
+// "zero date" for 0000-00-00, which servers without NO_ZERO_DATE accept, and
+// "invalid date" for a zero month or day such as 2024-00-15. Valid dates
+// return "".
+func invalidDate(s string) string {
+	if len(s) < len("2006-01-02") || s[4] != '-' || s[7] != '-' {
+		return ""
+	}
+	year, month, day := s[0:4], s[5:7], s[8:10]
+	switch {
+	case year == "0000" && month == "00" && day == "00":
+		return "zero date"
+	case month == "00" || day == "00":
+		return "invalid date"
+	}
+	return ""
+}
+
+func isJSON(columnType string) bool {
+	return baseType(columnType) == "json"
+}
+
+// prettyJSON reindents a JSON value inside a <pre> block. Invalid JSON is
+// rendered unchanged; either way the text is HTML-escaped.
+func prettyJSON(s string) template.HTML {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, []byte(s), "", "  "); err != nil {
+		return template.HTML(template.HTMLEscapeString(s))
+	}
+	return template.HTML(`<pre class="json">` + template.HTMLEscapeString(buf.String()) + `</pre>`)
+}
+
+// functions are available to every template. Cell values come straight from
+// the database, so any helper returning template.HTML must escape all of its
+// input with template.HTMLEscapeString and only add markup of its own, as
+// linkify and prettyJSON do. Anything else should return a plain string and
+// leave escaping to html/template.
+var functions = template.FuncMap{
+	"isJSON":       isJSON,
+	"prettyJSON":   prettyJSON,
+	"linkify":      linkify,
+	"isNumeric":    isNumeric,
+	"isTemporal":   isTemporal,
+	"formatNumber": formatNumber,
+	"formatDate":   formatDate,
+	"invalidDate":  invalidDate,
+	"join":         strings.Join,
+	"contains":     slices.Contains[[]string],
+	"sparkline":    sparkline,
+	"latestSize":   latestSize,
+	"tableURL":     tableURL,
+	"truncate": func(s string, n int) string {
+		s, _ = truncateCell(s, n)
+		return s
 	},
 	"formatTables": func(tables []types.Table) string {
 		var names []string
@@ -86,13 +430,134 @@ var functions = template.FuncMap{
 	},
 }
 
-func (app *application) newTemplateData(r *http.Request) *types.TemplateData {
-	return &types.TemplateData{}
+func (app *application) newTemplateData(w http.ResponseWriter, r *http.Request) *types.TemplateData {
+	return &types.TemplateData{
+		CurrentYear: time.Now().Year(),
+		CurrentPath: r.URL.Path,
+		BasePath:    app.config.basePath,
+		Connections: app.sourceNames(),
+		Flash:       app.popFlash(w, r),
+		Breadcrumbs: app.breadcrumbs(r.URL.Query().Get("conn"), r.URL.Query().Get("db"), r.URL.Query().Get("table")),
+		Watcher:     app.watcherStatus.get(),
+		Clients:     app.clientCount(),
+		Density:     density(r),
+	}
+}
+
+const flashCookie = "flash"
+
+// flash stores a message to be shown on the next rendered page.
+func (app *application) flash(w http.ResponseWriter, msg string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     flashCookie,
+		Value:    url.QueryEscape(msg),
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// popFlash returns the pending flash message, if any, and clears it.
+func (app *application) popFlash(w http.ResponseWriter, r *http.Request) string {
+	cookie, err := r.Cookie(flashCookie)
+	if err != nil {
+		return ""
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:   flashCookie,
+		Path:   "/",
+		MaxAge: -1,
+	})
+
+	msg, err := url.QueryUnescape(cookie.Value)
+	if err != nil {
+		return ""
+	}
+	return msg
 }
-func newTemplateCache() (map[string]*template.Template, error) {
+
+func (app *application) writeJSON(w http.ResponseWriter, status int, data any) {
+	js, err := json.Marshal(data)
+	if err != nil {
+		app.errorLog.Output(2, fmt.Sprintf("encoding JSON response: %v", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(js)
+}
+
+// sameOrigin reports whether r was sent by one of our own pages rather than
+// another site, going by Sec-Fetch-Site or else Origin. Requests with
+// neither, such as from curl, aren't from a browser page and are allowed.
+func sameOrigin(r *http.Request) bool {
+	switch r.Header.Get("Sec-Fetch-Site") {
+	case "same-origin", "none":
+		return true
+	case "":
+	default:
+		return false
+	}
+
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
+
+// rejectCrossSite answers 403 to state-changing requests sent by another
+// site's page, so that page can't pause broadcasts, refresh the cache or
+// save bookmarks and queries on a visitor's behalf.
+func (app *application) rejectCrossSite(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			if !sameOrigin(r) {
+				app.clientError(w, http.StatusForbidden)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// wantsJSON reports whether the client asked for JSON, either with
+// ?format=json or by listing application/json before text/html in Accept.
+func wantsJSON(r *http.Request) bool {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format == "json"
+	}
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case "application/json":
+			return true
+		case "text/html":
+			return false
+		}
+	}
+	return false
+}
+
+// devUIDir is where -dev mode reads templates from, relative to the working directory.
+const devUIDir = "ui"
+
+// newTemplateCache parses every page in fsys, which is laid out like
+// ui.Files, with the shared layout and partials. basePath is exposed to
+// templates as the base function for building links.
+func newTemplateCache(fsys fs.FS, basePath string) (map[string]*template.Template, error) {
 	cache := map[string]*template.Template{}
 
-	pages, err := fs.Glob(ui.Files, "html/pages/*.tmpl")
+	pages, err := fs.Glob(fsys, "html/pages/*.tmpl")
 	if err != nil {
 		return nil, err
 	}
@@ -106,7 +571,9 @@ func newTemplateCache() (map[string]*template.Template, error) {
 			page,
 		}
 
-		ts, err := template.New(name).Funcs(functions).ParseFS(ui.Files, patterns...)
+		ts, err := template.New(name).Funcs(functions).Funcs(template.FuncMap{
+			"base": func() string { return basePath },
+		}).ParseFS(fsys, patterns...)
 		if err != nil {
 			return nil, err
 		}
@@ -117,11 +584,11 @@ func newTemplateCache() (map[string]*template.Template, error) {
 	return cache, nil
 }
 
-func (app *application) serverError(w http.ResponseWriter, err error) {
-	trace := fmt.Sprintf("%s\n%s", err.Error(), debug.Stack())
+func (app *application) serverError(w http.ResponseWriter, r *http.Request, err error) {
+	trace := fmt.Sprintf("[%s] %s\n%s", requestIDFrom(r), err.Error(), debug.Stack())
 	app.errorLog.Output(2, trace)
 
-	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	app.renderError(w, http.StatusInternalServerError, "500.tmpl")
 }
 
 func (app *application) clientError(w http.ResponseWriter, status int) {
@@ -129,12 +596,41 @@ func (app *application) clientError(w http.ResponseWriter, status int) {
 }
 
 func (app *application) notFound(w http.ResponseWriter) {
-	app.clientError(w, http.StatusNotFound)
+	app.renderError(w, http.StatusNotFound, "404.tmpl")
+}
+
+// renderError renders an error page, falling back to the plain status text
+// if the template itself fails.
+func (app *application) renderError(w http.ResponseWriter, status int, page string) {
+	data := &types.TemplateData{
+		CurrentYear: time.Now().Year(),
+		BasePath:    app.config.basePath,
+		Connections: app.sourceNames(),
+		Watcher:     app.watcherStatus.get(),
+		Clients:     app.clientCount(),
+	}
+
+	ts, ok := app.templateCache[page]
+	if !ok {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	buf := new(bytes.Buffer)
+	if err := ts.ExecuteTemplate(buf, "base", data); err != nil {
+		app.errorLog.Printf("Rendering %s failed: %v", page, err)
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	buf.WriteTo(w)
 }
 
 func (app *application) logRequest(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		app.infoLog.Printf("%s - %s %s %s", r.RemoteAddr, r.Proto, r.Method, r.URL.RequestURI())
+		app.infoLog.Printf("[%s] %s - %s %s %s", requestIDFrom(r), r.RemoteAddr, r.Proto, r.Method, r.URL.RequestURI())
 
 		next.ServeHTTP(w, r)
 	})
@@ -145,9 +641,30 @@ func (app *application) recoverPanic(next http.Handler) http.Handler {
 		defer func() {
 			if err := recover(); err != nil {
 				w.Header().Set("Connection", "close")
-				app.serverError(w, fmt.Errorf("%s", err))
+				app.serverError(w, r, fmt.Errorf("%s", err))
 			}
 		}()
 		next.ServeHTTP(w, r)
 	})
 }
+
+// secureHeaders sets a restrictive Content-Security-Policy and related
+// headers. Scripts, styles and images are limited to the embedded static
+// files, apart from the web font, and connections to this host's WebSocket
+// plus any origins given with -csp-connect-src.
+func (app *application) secureHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		connect := []string{"'self'", "ws://" + r.Host, "wss://" + r.Host}
+		connect = append(connect, strings.Fields(app.config.cspConnectSrc)...)
+
+		w.Header().Set("Content-Security-Policy",
+			"default-src 'self'; style-src 'self' fonts.googleapis.com; font-src fonts.gstatic.com; "+
+				"img-src 'self' data:; connect-src "+strings.Join(connect, " ")+"; "+
+				"object-src 'none'; base-uri 'self'; form-action 'self'; frame-ancestors 'none'")
+		w.Header().Set("Referrer-Policy", "origin-when-cross-origin")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("X-Frame-Options", "deny")
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestInvalidDate(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"Zero datetime", "0000-00-00 00:00:00", "zero date"},
+		{"Zero date", "0000-00-00", "zero date"},
+		{"Zero month", "2024-00-15", "invalid date"},
+		{"Zero day", "2024-03-00 10:00:00", "invalid date"},
+		{"Valid date", "2024-03-15", ""},
+		{"Valid datetime", "2024-03-15 10:20:30.123456", ""},
+		{"TIME", "12:34:56", ""},
+		{"Long TIME", "-838:59:59", ""},
+		{"YEAR", "2024", ""},
+		{"Zero YEAR", "0000", ""},
+		{"Short", "2024-0", ""},
+		{"Empty", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := invalidDate(tt.value); got != tt.want {
+				t.Errorf("invalidDate(%q) = %q; want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSameOrigin(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    bool
+	}{
+		{"No headers", nil, true},
+		{"Same-origin fetch", map[string]string{"Sec-Fetch-Site": "same-origin"}, true},
+		{"Typed into the address bar", map[string]string{"Sec-Fetch-Site": "none"}, true},
+		{"Cross-site fetch", map[string]string{"Sec-Fetch-Site": "cross-site", "Origin": "http://example.com:4001"}, false},
+		{"Same-site subdomain", map[string]string{"Sec-Fetch-Site": "same-site"}, false},
+		{"Matching Origin", map[string]string{"Origin": "http://example.com:4001"}, true},
+		{"Other Origin", map[string]string{"Origin": "https://evil.example"}, false},
+		{"Opaque Origin", map[string]string{"Origin": "null"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "http://example.com:4001/ws/pause", nil)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			if got := sameOrigin(r); got != tt.want {
+				t.Errorf("sameOrigin = %t; want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTruncateCell(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		max  int
+		want string
+		cut  bool
+	}{
+		{"Short", "abc", 5, "abc", false},
+		{"Exact", "abcde", 5, "abcde", false},
+		{"ASCII", "abcdef", 3, "abc…", true},
+		{"Rune boundary", "ääää", 3, "ä…", true},
+		{"Disabled", "abcdef", 0, "abcdef", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, cut := truncateCell(tt.s, tt.max)
+			if got != tt.want || cut != tt.cut {
+				t.Errorf("truncateCell(%q, %d) = %q, %v; want %q, %v", tt.s, tt.max, got, cut, tt.want, tt.cut)
+			}
+		})
+	}
+}
+
+func TestRejectCrossSite(t *testing.T) {
+	app := &application{}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := app.rejectCrossSite(next)
+
+	tests := []struct {
+		name   string
+		method string
+		site   string
+		want   int
+	}{
+		{"Cross-site GET", http.MethodGet, "cross-site", http.StatusOK},
+		{"Cross-site POST", http.MethodPost, "cross-site", http.StatusForbidden},
+		{"Same-origin POST", http.MethodPost, "same-origin", http.StatusOK},
+	}
+
+	for _, path := range []string{"/refresh", "/bookmark", "/density", "/query", "/ws/pause"} {
+		for _, tt := range tests {
+			t.Run(path+" "+tt.name, func(t *testing.T) {
+				r := httptest.NewRequest(tt.method, path, nil)
+				r.Header.Set("Sec-Fetch-Site", tt.site)
+				rr := httptest.NewRecorder()
+				handler.ServeHTTP(rr, r)
+				if rr.Code != tt.want {
+					t.Errorf("status = %d; want %d", rr.Code, tt.want)
+				}
+			})
+		}
+	}
+}
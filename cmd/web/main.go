@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"flag"
 	"fmt"
@@ -8,104 +9,321 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/go-mysql-org/go-mysql/replication"
 	"github.com/gorilla/websocket"
 
+	"sequelscope.jonnevuorela.com/internal/models"
 	"sequelscope.jonnevuorela.com/types"
+	"sequelscope.jonnevuorela.com/ui"
 )
 
+type config struct {
+	explainQueries      bool
+	eventHistory        int
+	watchDatabases      string
+	watchTables         string
+	metadataTTL         time.Duration
+	rateLimit           float64
+	rateBurst           int
+	clientIPHeader      string
+	includeTxnMarkers   bool
+	wsReadBuffer        int
+	wsWriteBuffer       int
+	wsCompression       bool
+	exactCountThreshold int64
+	redact              string
+	basePath            string
+	binlogConnection    string
+	dbConnectTimeout    time.Duration
+	dev                 bool
+	maxCellBytes        int
+	maxBroadcastRows    int
+	maxQueryBytes       int
+	auditLog            string
+	auditLogMaxBytes    int64
+	store               string
+	defaultLimit        int
+	maxLimit            int
+	displayTZ           *time.Location
+	cspConnectSrc       string
+	databases           string
+	sizeInterval        time.Duration
+	autoIncrementWarn   float64
+	sqlMode             string
+	sessionTimeZone     string
+	readOnly            bool
+	wsWriteTimeout      time.Duration
+}
+
 type application struct {
+	config        config
 	errorLog      *log.Logger
 	infoLog       *log.Logger
-	db            *sql.DB
-	dsn           string
+	sources       []*source
+	binlogSource  *source
 	entries       []*types.Entry
+	entriesMux    sync.RWMutex
 	templateCache map[string]*template.Template
+	events        *eventLog
+	watch         *watchFilter
+	databases     databaseAllowlist
+	sizes         *sizeHistory
+	metadata      *metadataCache
+	activity      *activityCounter
+	deltas        *deltaCounter
+	redact        *redactor
+	rowColumns    *columnNameCache
+	bookmarks     *models.BookmarkModel
+	queries       *models.QueryModel
+	audit         *auditLog
+	binlogFile    string
+	eventRate     eventRate
 
 	binlogSyncer   *replication.BinlogSyncer
 	binlogStreamer *replication.BinlogStreamer
-	clients        map[*websocket.Conn]bool
+	binlogCancel   context.CancelFunc
+	binlogDone     chan struct{}
+	upgrader       websocket.Upgrader
+	clients        map[*websocket.Conn]*wsClient
 	clientsMux     sync.RWMutex
+	watcherStatus  watcherStatus
+	paused         atomic.Bool
 }
 
-var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
+func newUpgrader(cfg config) websocket.Upgrader {
+	return websocket.Upgrader{
+		ReadBufferSize:    cfg.wsReadBuffer,
+		WriteBufferSize:   cfg.wsWriteBuffer,
+		EnableCompression: cfg.wsCompression,
+		// The socket streams every captured query, so only our own pages
+		// may open it.
+		CheckOrigin: sameOrigin,
+	}
 }
 
 func main() {
-	addr := flag.String("addr", ":4001", "HTTP network address")
-	dsn := flag.String("dsn", formDsn(), "MySQL data source name")
+	addr := flag.String("addr", ":4001", "HTTP network address, or a unix socket path (absolute or unix:path)")
+	var dsns dsnList
+	flag.Var(&dsns, "dsn", "MySQL data source name, optionally prefixed with name=; repeat for several connections")
+
+	var cfg config
+	flag.BoolVar(&cfg.explainQueries, "explain-queries", false, "Run EXPLAIN on SELECT statements captured from the binlog")
+	flag.IntVar(&cfg.eventHistory, "event-history", 200, "Number of recent binlog events kept in memory")
+	flag.StringVar(&cfg.watchDatabases, "watch-databases", "", "Comma-separated databases whose binlog events are reported (default all)")
+	flag.StringVar(&cfg.watchTables, "watch-tables", "", "Comma-separated db.table or db.* patterns whose binlog events are reported (default all)")
+	flag.DurationVar(&cfg.metadataTTL, "metadata-ttl", 5*time.Minute, "How long cached table metadata is reused")
+	flag.Float64Var(&cfg.rateLimit, "rate-limit", 20, "Requests per second allowed per client IP (0 disables); behind a reverse proxy or on a unix socket all clients share one limit unless -client-ip-header is set")
+	flag.IntVar(&cfg.rateBurst, "rate-burst", 40, "Maximum burst of requests per client IP")
+	flag.StringVar(&cfg.clientIPHeader, "client-ip-header", "", "Request header a trusted reverse proxy puts the client IP in, e.g. X-Forwarded-For or X-Real-IP")
+	flag.BoolVar(&cfg.includeTxnMarkers, "include-txn-markers", false, "Broadcast BEGIN/COMMIT/ROLLBACK/SAVEPOINT query events")
+	flag.IntVar(&cfg.maxBroadcastRows, "max-broadcast-rows", 10, "Rows included in each broadcast binlog row event; bulk changes are cut short and flagged truncated (0 sends all)")
+	flag.IntVar(&cfg.maxQueryBytes, "max-query-bytes", 4096, "Binlog queries longer than this are cut short in broadcasts and the event list; the audit log keeps them whole (0 disables)")
+	flag.IntVar(&cfg.wsReadBuffer, "ws-read-buffer", 1024, "WebSocket read buffer size in bytes")
+	flag.IntVar(&cfg.wsWriteBuffer, "ws-write-buffer", 1024, "WebSocket write buffer size in bytes")
+	flag.Int64Var(&cfg.exactCountThreshold, "exact-count-threshold", 100000, "Tables estimated above this many rows show an approximate count instead of COUNT(*)")
+	flag.Float64Var(&cfg.autoIncrementWarn, "auto-increment-warn", 80, "Flag tables whose AUTO_INCREMENT has used this percentage of the column type's range")
+	flag.DurationVar(&cfg.sizeInterval, "size-interval", 5*time.Minute, "How often database sizes are sampled for the home page growth charts (0 disables)")
+	flag.StringVar(&cfg.redact, "redact", "", "Comma-separated db.table.column patterns (wildcards allowed) whose values are shown as ***")
+	flag.StringVar(&cfg.databases, "databases", "", "Comma-separated databases that may be browsed; others are hidden and answer 404, and the query console is disabled (default all)")
+	flag.StringVar(&cfg.basePath, "base-path", "", "URL path prefix when served behind a reverse proxy, e.g. /dbscope")
+	flag.StringVar(&cfg.binlogConnection, "binlog-connection", "", "Name of the connection whose binlog is watched (default the first, \"none\" disables live updates)")
+	flag.DurationVar(&cfg.dbConnectTimeout, "db-connect-timeout", 30*time.Second, "How long to keep retrying the initial database connection")
+	flag.IntVar(&cfg.maxCellBytes, "max-cell-bytes", 1024, "Table grid cells longer than this are cut short with a link to the full value (0 disables)")
+	flag.Func("display-tz", "IANA time zone DATETIME and TIMESTAMP values are shown in, e.g. Europe/Helsinki (default as stored)", func(name string) error {
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			return err
+		}
+		cfg.displayTZ = loc
+		return nil
+	})
+	flag.StringVar(&cfg.sqlMode, "sql-mode", "", "sql_mode set on every database connection (default the server's)")
+	flag.StringVar(&cfg.sessionTimeZone, "session-time-zone", "", "time_zone set on every database connection, e.g. +00:00 or Europe/Helsinki (default the server's)")
+	flag.BoolVar(&cfg.readOnly, "read-only", true, "Make every database session read-only so accidental writes fail at the server")
+	flag.StringVar(&cfg.auditLog, "audit-log", "", "File that every captured binlog query in a -databases schema is appended to as a JSON line")
+	flag.Int64Var(&cfg.auditLogMaxBytes, "audit-log-max-bytes", 100<<20, "Rotate the audit log once it exceeds this many bytes (0 never rotates)")
+	flag.StringVar(&cfg.store, "store", models.MemoryStore, "SQLite file that keeps app data such as bookmarks and saved queries across restarts; :memory: keeps it in memory only")
+	flag.IntVar(&cfg.defaultLimit, "default-limit", 100, "Rows shown per table page unless ?limit= asks for another amount")
+	flag.IntVar(&cfg.maxLimit, "max-limit", 1000, "Largest ?limit= accepted for table pages")
+	flag.BoolVar(&cfg.dev, "dev", false, "Re-read templates from ./ui on every request instead of using the embedded copies")
+	flag.BoolVar(&cfg.wsCompression, "ws-compression", false, "Negotiate per-message compression for WebSocket connections")
+	flag.DurationVar(&cfg.wsWriteTimeout, "ws-write-timeout", 10*time.Second, "Drop a WebSocket client when a single write to it takes longer than this (0 disables)")
+	flag.StringVar(&cfg.cspConnectSrc, "csp-connect-src", "", "Extra space-separated origins the pages may connect to, e.g. a WebSocket endpoint behind a proxy")
+
+	configPath := flag.String("config", "", "YAML file of flag-name: value settings; command-line flags take precedence")
 
 	flag.Parse()
 
+	if err := applyConfig(flag.CommandLine, *configPath); err != nil {
+		log.Fatal(err)
+	}
+
+	if cfg.maxLimit < 1 {
+		log.Fatal("-max-limit must be at least 1")
+	}
+	if cfg.defaultLimit < 1 || cfg.defaultLimit > cfg.maxLimit {
+		log.Fatalf("-default-limit must be between 1 and -max-limit (%d)", cfg.maxLimit)
+	}
+
 	infoLog := log.New(os.Stdout, "\033[42;30mINFO\033[0m\t", log.Ldate|log.Ltime)
 	errorLog := log.New(os.Stderr, "\033[41;30mERROR\033[0m\t", log.Ldate|log.Ltime|log.Lshortfile)
 
-	db, err := sql.Open("mysql", *dsn)
+	if len(dsns) == 0 {
+		dsns = append(dsns, formDsn())
+	}
+
+	session := sessionSettings{sqlMode: cfg.sqlMode, timeZone: cfg.sessionTimeZone, readOnly: cfg.readOnly}
+	sources, err := openSources(dsns, session, cfg.dbConnectTimeout, infoLog)
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer db.Close()
+	for _, src := range sources {
+		defer src.db.Close()
+	}
+
+	cfg.basePath = strings.TrimRight(cfg.basePath, "/")
+	if cfg.basePath != "" && !strings.HasPrefix(cfg.basePath, "/") {
+		cfg.basePath = "/" + cfg.basePath
+	}
 
-	if err = db.Ping(); err != nil {
+	store, err := models.OpenStore(cfg.store)
+	if err != nil {
 		log.Fatal(err)
 	}
+	defer store.Close()
 
-	templateCache, err := newTemplateCache()
+	templateCache, err := newTemplateCache(ui.Files, cfg.basePath)
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	app := &application{
-		db:            db,
-		dsn:           *dsn,
+		config:        cfg,
+		sources:       sources,
 		entries:       []*types.Entry{},
 		errorLog:      errorLog,
 		infoLog:       infoLog,
 		templateCache: templateCache,
-		clients:       make(map[*websocket.Conn]bool),
+		events:        newEventLog(cfg.eventHistory),
+		watch:         newWatchFilter(cfg.watchDatabases, cfg.watchTables),
+		databases:     newDatabaseAllowlist(cfg.databases),
+		sizes:         newSizeHistory(),
+		metadata:      newMetadataCache(cfg.metadataTTL),
+		activity:      newActivityCounter(),
+		deltas:        newDeltaCounter(),
+		redact:        newRedactor(cfg.redact),
+		rowColumns:    newColumnNameCache(),
+		bookmarks:     &models.BookmarkModel{DB: store},
+		queries:       &models.QueryModel{DB: store},
+		upgrader:      newUpgrader(cfg),
+		clients:       make(map[*websocket.Conn]*wsClient),
+	}
+	app.watcherStatus.set(watcherStarting, "")
+	if cfg.binlogConnection != binlogDisabled {
+		binlogSource, ok := app.sourceByName(cfg.binlogConnection)
+		if !ok {
+			log.Fatalf("unknown -binlog-connection %q", cfg.binlogConnection)
+		}
+		app.binlogSource = binlogSource
+	}
+
+	if err := app.getDatabases(); err != nil {
+		log.Fatal(err)
+	}
+
+	if cfg.auditLog != "" {
+		app.audit, err = openAuditLog(cfg.auditLog, cfg.auditLogMaxBytes)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer app.audit.Close()
 	}
-	app.getDatabases()
 
 	app.setupBinlogWatcher()
+	go app.broadcastStats(time.Second)
+	if cfg.sizeInterval > 0 {
+		go app.sampleSizes(cfg.sizeInterval)
+	}
 
-	defer app.binlogSyncer.Close()
+	ln, err := listen(*addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if path, ok := socketPath(*addr); ok {
+		log.Printf("Starting server on unix socket %s", path)
+	} else {
+		log.Printf("Starting server on http://localhost%s", *addr)
+	}
 
-	log.Printf("Starting server on http://localhost%s", *addr)
-	err = http.ListenAndServe(*addr, app.routes())
-	log.Fatal(err)
+	srv := &http.Server{
+		Handler:  app.routes(),
+		ErrorLog: errorLog,
+	}
+	if err := app.serve(srv, ln); err != nil {
+		log.Fatal(err)
+	}
 }
 
+// formDsn prompts for connection details until they produce a DSN the
+// server accepts. Ending input (Ctrl+D) aborts.
 func formDsn() string {
+	for {
+		dsn, err := promptDsn()
+		if err != nil {
+			log.Fatalf("dsn formatting aborted: %v", err)
+		}
 
+		if err := testDsn(dsn); err != nil {
+			println("Connection failed:", err.Error())
+			println("Please try again.")
+			continue
+		}
+		return dsn
+	}
+}
+
+func promptDsn() (string, error) {
 	println("Enter username for database: ")
 	var user string
-	_, err := fmt.Scan(&user)
+	if _, err := fmt.Scan(&user); err != nil {
+		return "", err
+	}
 
 	println("Enter password for the username: ")
 	var password string
-	_, err = fmt.Scan(&password)
+	if _, err := fmt.Scan(&password); err != nil {
+		return "", err
+	}
 
 	println("Enter database port: ")
 	var port string
-	_, err = fmt.Scan(&port)
+	if _, err := fmt.Scan(&port); err != nil {
+		return "", err
+	}
 
 	println("Enter database name: ")
 	var dbname string
-	_, err = fmt.Scan(&dbname)
+	if _, err := fmt.Scan(&dbname); err != nil {
+		return "", err
+	}
 
-	if err != nil {
+	return fmt.Sprintf("%v:%v@tcp(127.0.0.1:%v)/%v?parseTime=true", user, password, port, dbname), nil
+}
 
-		fmt.Errorf("dsn formatting failed: %v", err)
+// testDsn opens dsn and pings it with a short timeout.
+func testDsn(dsn string) error {
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return err
 	}
+	defer db.Close()
 
-	dsn := fmt.Sprintf("%v:%v@tcp(127.0.0.1:%v)/%v?parseTime=true", user, password, port, dbname)
-	fmt.Print(dsn)
-	return dsn
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return db.PingContext(ctx)
 }
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http"
+)
+
+// isEventMessage reports whether message carries a database event, which is
+// dropped while paused, as opposed to housekeeping such as stats.
+func isEventMessage(message map[string]any) bool {
+	return message["type"] != "stats"
+}
+
+// pauseBroadcasts stops event broadcasts to every client. Events arriving
+// while paused are dropped, not buffered.
+func (app *application) pauseBroadcasts(w http.ResponseWriter, r *http.Request) {
+	app.setPaused(w, r, true)
+}
+
+func (app *application) resumeBroadcasts(w http.ResponseWriter, r *http.Request) {
+	app.setPaused(w, r, false)
+}
+
+func (app *application) setPaused(w http.ResponseWriter, r *http.Request, paused bool) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	app.paused.Store(paused)
+	if paused {
+		app.infoLog.Print("Broadcasts paused")
+	} else {
+		app.infoLog.Print("Broadcasts resumed")
+	}
+	app.writeJSON(w, http.StatusOK, map[string]any{"paused": paused})
+}
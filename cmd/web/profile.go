@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"sequelscope.jonnevuorela.com/types"
+)
+
+// profileTopValues is how many of the most frequent values a column profile
+// lists.
+const profileTopValues = 10
+
+// columnProfile computes counts, bounds and the most frequent values of
+// column in db.table. The column name must come from the table's metadata.
+func (s *source) columnProfile(ctx context.Context, db, table string, column types.Column) (*types.ColumnProfile, error) {
+	col := quoteIdentifier(column.Field)
+	from := quoteIdentifier(db) + "." + quoteIdentifier(table)
+
+	p := &types.ColumnProfile{
+		Connection: s.name,
+		Database:   db,
+		Table:      table,
+		Column:     column.Field,
+		Type:       column.Type,
+	}
+
+	var nulls sql.NullInt64
+	var minVal, maxVal []byte
+	stmt := fmt.Sprintf("SELECT COUNT(*), COUNT(DISTINCT %s), SUM(%s IS NULL), MIN(%s), MAX(%s) FROM %s",
+		col, col, col, col, from)
+	if err := s.db.QueryRowContext(ctx, stmt).Scan(&p.Rows, &p.Distinct, &nulls, &minVal, &maxVal); err != nil {
+		return nil, err
+	}
+	p.Nulls = nulls.Int64
+	p.Min = formatCell(minVal)
+	p.Max = formatCell(maxVal)
+
+	stmt = fmt.Sprintf("SELECT %s, COUNT(*) AS n FROM %s GROUP BY %s ORDER BY n DESC LIMIT %d",
+		col, from, col, profileTopValues)
+	rows, err := s.db.QueryContext(ctx, stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var value []byte
+		var vc types.ValueCount
+		if err := rows.Scan(&value, &vc.Count); err != nil {
+			return nil, err
+		}
+		vc.Null = value == nil
+		vc.Value = formatCell(value)
+		p.Top = append(p.Top, vc)
+	}
+	return p, rows.Err()
+}
+
+// columnView renders the profile of a single column.
+func (app *application) columnView(w http.ResponseWriter, r *http.Request) {
+	dbName := r.URL.Query().Get("db")
+	tableName := r.URL.Query().Get("table")
+	colName := r.URL.Query().Get("col")
+
+	if dbName == "" || tableName == "" || colName == "" {
+		app.notFound(w)
+		return
+	}
+
+	src, ok := app.source(r)
+	if !ok {
+		app.notFound(w)
+		return
+	}
+
+	columns, err := src.tableColumns(dbName, tableName)
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	var column *types.Column
+	for i := range columns {
+		if columns[i].Field == colName {
+			column = &columns[i]
+			break
+		}
+	}
+	if column == nil {
+		app.notFound(w)
+		return
+	}
+
+	profile, err := src.columnProfile(r.Context(), dbName, tableName, *column)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if app.redact.match(dbName, tableName, colName) {
+		profile.Redacted = true
+		profile.Min, profile.Max = redactedValue, redactedValue
+		for i := range profile.Top {
+			if !profile.Top[i].Null {
+				profile.Top[i].Value = redactedValue
+			}
+		}
+	}
+
+	data := app.newTemplateData(w, r)
+	data.Entry = &types.Entry{
+		Title:      dbName,
+		Connection: src.name,
+		Tables:     []types.Table{{TableName: tableName}},
+	}
+	data.Profile = profile
+	app.render(w, http.StatusOK, "column.tmpl", data)
+}
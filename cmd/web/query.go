@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"sequelscope.jonnevuorela.com/internal/models"
+	"sequelscope.jonnevuorela.com/types"
+)
+
+const (
+	// queryTimeout bounds statements run from the query console.
+	queryTimeout = 30 * time.Second
+	// queryRowLimit caps how many rows the console renders.
+	queryRowLimit = 1000
+)
+
+// isConsoleStatement reports whether the console may run query. Only
+// statements that read data are allowed; writes are additionally blocked by
+// running inside a read-only transaction. A read-only transaction still lets
+// SELECT write files on the server or lock rows, so those forms are refused.
+func isConsoleStatement(query string) bool {
+	fields := consoleWords(query)
+	if len(fields) == 0 {
+		return false
+	}
+	switch fields[0] {
+	case "SELECT", "SHOW", "EXPLAIN", "DESCRIBE", "DESC", "WITH":
+	default:
+		return false
+	}
+
+	for i := range fields {
+		rest := fields[i:]
+		switch {
+		case hasWords(rest, "INTO", "OUTFILE"), hasWords(rest, "INTO", "DUMPFILE"),
+			hasWords(rest, "FOR", "UPDATE"), hasWords(rest, "FOR", "SHARE"),
+			hasWords(rest, "LOCK", "IN", "SHARE", "MODE"):
+			return false
+		}
+	}
+	return true
+}
+
+// consoleWords splits query into upper-cased words, skipping quoted strings
+// and identifiers so their contents can't be mistaken for keywords. Comments
+// are kept, since MySQL runs the contents of /*! ... */ comments.
+func consoleWords(query string) []string {
+	var b strings.Builder
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch c {
+		case '\'', '"', '`':
+			for i++; i < len(query); i++ {
+				if query[i] == '\\' && c != '`' {
+					i++
+				} else if query[i] == c {
+					if i+1 < len(query) && query[i+1] == c {
+						i++
+					} else {
+						break
+					}
+				}
+			}
+			b.WriteByte(' ')
+		default:
+			if c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' {
+				b.WriteByte(c)
+			} else {
+				b.WriteByte(' ')
+			}
+		}
+	}
+	return strings.Fields(strings.ToUpper(b.String()))
+}
+
+// hasWords reports whether fields starts with words.
+func hasWords(fields []string, words ...string) bool {
+	if len(fields) < len(words) {
+		return false
+	}
+	for i, w := range words {
+		if fields[i] != w {
+			return false
+		}
+	}
+	return true
+}
+
+// supportsExplainAnalyze reports whether the server understands
+// EXPLAIN ANALYZE, which MySQL added in 8.0.18.
+func (s *source) supportsExplainAnalyze() bool {
+	v := s.version
+	return !v.mariaDB && v.atLeast(8, 0, 18)
+}
+
+// scanTableData reads up to limit rows into a TableData.
+func scanTableData(rows *sql.Rows, limit int) (*types.TableData, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]sql.RawBytes, len(columns))
+	scanArgs := make([]interface{}, len(values))
+	for i := range values {
+		scanArgs[i] = &values[i]
+	}
+
+	data := &types.TableData{Columns: columns}
+	for rows.Next() {
+		if len(data.Rows) >= limit {
+			break
+		}
+		if err := rows.Scan(scanArgs...); err != nil {
+			return nil, err
+		}
+		row := make(map[string]string)
+		for i, col := range values {
+			if col == nil {
+				row[columns[i]] = "NULL"
+			} else {
+				row[columns[i]] = formatCell(col)
+			}
+		}
+		data.Rows = append(data.Rows, row)
+	}
+
+	return data, rows.Err()
+}
+
+// runConsoleQuery executes stmt against db in a read-only transaction.
+func (s *source) runConsoleQuery(ctx context.Context, db, stmt string) (*types.TableData, error) {
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if db != "" {
+		if _, err := conn.ExecContext(ctx, "USE "+quoteIdentifier(db)); err != nil {
+			return nil, err
+		}
+	}
+
+	tx, err := conn.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanTableData(rows, queryRowLimit)
+}
+
+func (app *application) queryConsole(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(w, r)
+	data.Entries = app.listEntries()
+	console := &types.QueryConsole{}
+	data.Query = console
+
+	saved, err := app.queries.ListQueries()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	console.Saved = saved
+
+	// Console results can't be traced back to db.table.column, so there is
+	// no way to redact them.
+	if app.redact.enabled() {
+		console.Error = "The query console is disabled while -redact is set."
+		app.render(w, http.StatusForbidden, "query.tmpl", data)
+		return
+	}
+	// Statements can name any schema, so -databases can't be enforced here.
+	if len(app.databases) > 0 {
+		console.Error = "The query console is disabled while -databases is set."
+		app.render(w, http.StatusForbidden, "query.tmpl", data)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		console.Connection = r.URL.Query().Get("conn")
+		console.Database = r.URL.Query().Get("db")
+
+		// ?saved=id loads a saved query into the form.
+		if id, err := strconv.Atoi(r.URL.Query().Get("saved")); err == nil {
+			q, err := app.queries.Get(id)
+			if errors.Is(err, models.ErrNoRecord) {
+				app.notFound(w)
+				return
+			} else if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+			console.Connection, console.Database, console.SQL = q.Connection, q.Database, q.SQL
+		}
+
+		app.render(w, http.StatusOK, "query.tmpl", data)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	// The database selector submits "connection/database".
+	console.Connection, console.Database, _ = strings.Cut(r.PostForm.Get("db"), "/")
+	src, ok := app.sourceByName(console.Connection)
+	if !ok {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	console.SQL = strings.TrimSpace(r.PostForm.Get("sql"))
+	console.Analyze = r.PostForm.Get("analyze") != ""
+
+	stmt := strings.TrimSuffix(console.SQL, ";")
+	if !isConsoleStatement(stmt) {
+		console.Error = "Only SELECT, SHOW, EXPLAIN, DESCRIBE and WITH statements can be run, " +
+			"without INTO OUTFILE/DUMPFILE or row locks."
+		app.render(w, http.StatusUnprocessableEntity, "query.tmpl", data)
+		return
+	}
+
+	if name := strings.TrimSpace(r.PostForm.Get("save_name")); name != "" {
+		id, err := app.queries.SaveQuery(name, console.Connection, console.Database, console.SQL)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		console.Saved = append(console.Saved, types.SavedQuery{Id: id, Name: name})
+		console.Note = fmt.Sprintf("Saved as %q.", name)
+	}
+
+	if console.Analyze {
+		first := strings.ToUpper(strings.Fields(stmt)[0])
+		switch {
+		case first != "SELECT" && first != "WITH":
+			console.Error = "Only SELECT statements can be analyzed."
+			app.render(w, http.StatusUnprocessableEntity, "query.tmpl", data)
+			return
+		case src.supportsExplainAnalyze():
+			stmt = "EXPLAIN ANALYZE " + stmt
+		default:
+			console.Note = strings.TrimSpace(console.Note + " EXPLAIN ANALYZE requires MySQL 8.0.18 or newer; showing EXPLAIN instead.")
+			stmt = "EXPLAIN " + stmt
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), queryTimeout)
+	defer cancel()
+
+	result, err := src.runConsoleQuery(ctx, console.Database, stmt)
+	if err != nil {
+		console.Error = err.Error()
+		app.render(w, http.StatusUnprocessableEntity, "query.tmpl", data)
+		return
+	}
+
+	// EXPLAIN ANALYZE returns its tree as a single text column.
+	if console.Analyze && strings.HasPrefix(stmt, "EXPLAIN ANALYZE ") && len(result.Columns) == 1 {
+		var plan []string
+		for _, row := range result.Rows {
+			plan = append(plan, row[result.Columns[0]])
+		}
+		console.Plan = strings.Join(plan, "\n")
+	} else {
+		console.Result = result
+	}
+
+	app.render(w, http.StatusOK, "query.tmpl", data)
+}
@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+func TestIsConsoleStatement(t *testing.T) {
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"SELECT * FROM t", true},
+		{"  show tables", true},
+		{"WITH x AS (SELECT 1) SELECT * FROM x", true},
+		{"SELECT 'into outfile' AS s, `for update` FROM t", true},
+		{`SELECT "it\"s for update" FROM t`, true},
+		{"SELECT 'it''s lock in share mode'", true},
+		{"DELETE FROM t", false},
+		{"", false},
+		{"SELECT * FROM t INTO OUTFILE '/tmp/t.csv'", false},
+		{"select * into dumpfile '/tmp/x' from t", false},
+		{"SELECT 1 /*!INTO OUTFILE '/tmp/x'*/", false},
+		{"SELECT * FROM t FOR UPDATE", false},
+		{"SELECT * FROM t\nFOR\tSHARE", false},
+		{"SELECT * FROM t LOCK IN SHARE MODE", false},
+		{"SELECT 'a' FOR UPDATE", false},
+	}
+
+	for _, tt := range tests {
+		if got := isConsoleStatement(tt.query); got != tt.want {
+			t.Errorf("isConsoleStatement(%q) = %t; want %t", tt.query, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenBucket refills at rate tokens per second up to burst.
+type tokenBucket struct {
+	tokens   float64
+	last     time.Time
+	lastSeen time.Time
+}
+
+// rateLimiter keeps one token bucket per client IP.
+type rateLimiter struct {
+	mu      sync.Mutex
+	rate    float64
+	burst   float64
+	buckets map[string]*tokenBucket
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	rl := &rateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*tokenBucket),
+	}
+	go rl.cleanup(time.Minute, 3*time.Minute)
+	return rl
+}
+
+// allow takes a token for ip. When the bucket is empty it returns false and
+// how long until the next token is available.
+func (rl *rateLimiter) allow(ip string) (bool, time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	b, ok := rl.buckets[ip]
+	if !ok {
+		b = &tokenBucket{tokens: rl.burst, last: now}
+		rl.buckets[ip] = b
+	}
+	b.lastSeen = now
+
+	b.tokens = math.Min(rl.burst, b.tokens+now.Sub(b.last).Seconds()*rl.rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / rl.rate * float64(time.Second))
+		return false, wait
+	}
+	b.tokens--
+	return true, 0
+}
+
+// cleanup periodically forgets clients that have been idle longer than idle.
+func (rl *rateLimiter) cleanup(every, idle time.Duration) {
+	for range time.Tick(every) {
+		rl.mu.Lock()
+		for ip, b := range rl.buckets {
+			if time.Since(b.lastSeen) > idle {
+				delete(rl.buckets, ip)
+			}
+		}
+		rl.mu.Unlock()
+	}
+}
+
+// rateLimitExempt reports whether path is never rate limited: the static
+// assets every page load pulls in.
+func rateLimitExempt(path string) bool {
+	return strings.HasPrefix(path, "/static/") || path == "/favicon.ico"
+}
+
+// clientIP identifies the client r came from. With a header configured, as
+// set by a reverse proxy, its value is used; for X-Forwarded-For that is the
+// last address, the one the proxy added. Otherwise it is the peer address,
+// which every client shares when they come through a proxy or the unix
+// socket.
+func clientIP(r *http.Request, header string) string {
+	if header != "" {
+		if value := r.Header.Get(header); value != "" {
+			if i := strings.LastIndexByte(value, ','); i >= 0 {
+				value = value[i+1:]
+			}
+			return strings.TrimSpace(value)
+		}
+	}
+
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	return ip
+}
+
+func (app *application) rateLimit(next http.Handler) http.Handler {
+	if app.config.rateLimit <= 0 {
+		return next
+	}
+	limiter := newRateLimiter(app.config.rateLimit, app.config.rateBurst)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rateLimitExempt(strings.TrimPrefix(r.URL.Path, app.config.basePath)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if ok, wait := limiter.allow(clientIP(r, app.config.clientIPHeader)); !ok {
+			seconds := int(math.Ceil(wait.Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(seconds))
+			app.clientError(w, http.StatusTooManyRequests)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
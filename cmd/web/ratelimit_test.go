@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		remoteAddr string
+		header     string
+		headers    map[string]string
+		want       string
+	}{
+		{"Peer address", "192.0.2.1:5555", "", nil, "192.0.2.1"},
+		{"Header ignored unless configured", "192.0.2.1:5555", "", map[string]string{"X-Forwarded-For": "198.51.100.7"}, "192.0.2.1"},
+		{"X-Forwarded-For last hop", "127.0.0.1:5555", "X-Forwarded-For", map[string]string{"X-Forwarded-For": "203.0.113.9, 198.51.100.7"}, "198.51.100.7"},
+		{"X-Real-IP", "127.0.0.1:5555", "X-Real-IP", map[string]string{"X-Real-IP": "198.51.100.7"}, "198.51.100.7"},
+		{"Missing header", "127.0.0.1:5555", "X-Real-IP", nil, "127.0.0.1"},
+		{"Unix socket peer", "@", "", nil, "@"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			if got := clientIP(r, tt.header); got != tt.want {
+				t.Errorf("clientIP = %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
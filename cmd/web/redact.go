@@ -0,0 +1,51 @@
+package main
+
+import (
+	"path"
+	"strings"
+)
+
+// redactedValue replaces the contents of redacted cells.
+const redactedValue = "***"
+
+// redactor matches columns against db.table.column patterns whose segments
+// may contain shell-style wildcards. A bare column pattern applies to every
+// table.
+type redactor struct {
+	patterns [][3]string
+}
+
+func newRedactor(spec string) *redactor {
+	r := &redactor{}
+	for _, item := range splitList(spec) {
+		parts := strings.Split(strings.ToLower(item), ".")
+		for len(parts) < 3 {
+			parts = append([]string{"*"}, parts...)
+		}
+		r.patterns = append(r.patterns, [3]string{parts[0], parts[1], strings.Join(parts[2:], ".")})
+	}
+	return r
+}
+
+// enabled reports whether any column is redacted.
+func (r *redactor) enabled() bool {
+	return len(r.patterns) > 0
+}
+
+// match reports whether db.table.column should be redacted.
+func (r *redactor) match(db, table, column string) bool {
+	name := [3]string{strings.ToLower(db), strings.ToLower(table), strings.ToLower(column)}
+	for _, p := range r.patterns {
+		matched := true
+		for i := range p {
+			if ok, err := path.Match(p[i], name[i]); err != nil || !ok {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
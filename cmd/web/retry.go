@@ -0,0 +1,37 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	connectInitialBackoff = 500 * time.Millisecond
+	connectMaxBackoff     = 10 * time.Second
+)
+
+// pingWithRetry pings db until it answers or timeout has elapsed, doubling
+// the wait between attempts. A zero timeout tries exactly once.
+func pingWithRetry(db *sql.DB, name string, timeout time.Duration, logger *log.Logger) error {
+	deadline := time.Now().Add(timeout)
+	backoff := connectInitialBackoff
+
+	for attempt := 1; ; attempt++ {
+		err := db.Ping()
+		if err == nil {
+			return nil
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return fmt.Errorf("connection %s: giving up after %d attempt(s): %w", name, attempt, err)
+		}
+
+		wait := min(backoff, remaining)
+		logger.Printf("Connection %s not ready (attempt %d): %v; retrying in %s", name, attempt, err, wait.Round(time.Millisecond))
+		time.Sleep(wait)
+		backoff = min(backoff*2, connectMaxBackoff)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	mysqlDriver "github.com/go-sql-driver/mysql"
+	"sequelscope.jonnevuorela.com/types"
+)
+
+// routines lists the stored procedures and functions of db.
+func (s *source) routines(db string) ([]types.Routine, error) {
+	rows, err := s.db.Query(`SELECT ROUTINE_NAME, ROUTINE_TYPE, DEFINER, CREATED, LAST_ALTERED, ROUTINE_COMMENT
+		FROM information_schema.ROUTINES
+		WHERE ROUTINE_SCHEMA = ?
+		ORDER BY ROUTINE_TYPE, ROUTINE_NAME`, db)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var routines []types.Routine
+	for rows.Next() {
+		var r types.Routine
+		if err := rows.Scan(&r.Name, &r.Type, &r.Definer, &r.Created, &r.Modified, &r.Comment); err != nil {
+			return nil, err
+		}
+		routines = append(routines, r)
+	}
+	return routines, rows.Err()
+}
+
+// triggers lists the triggers of db with their statements.
+func (s *source) triggers(db string) ([]types.Trigger, error) {
+	rows, err := s.db.Query(`SELECT TRIGGER_NAME, ACTION_TIMING, EVENT_MANIPULATION, EVENT_OBJECT_TABLE, ACTION_STATEMENT
+		FROM information_schema.TRIGGERS
+		WHERE TRIGGER_SCHEMA = ?
+		ORDER BY EVENT_OBJECT_TABLE, ACTION_TIMING, EVENT_MANIPULATION, ACTION_ORDER`, db)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var triggers []types.Trigger
+	for rows.Next() {
+		var t types.Trigger
+		if err := rows.Scan(&t.Name, &t.Timing, &t.Event, &t.Table, &t.Statement); err != nil {
+			return nil, err
+		}
+		triggers = append(triggers, t)
+	}
+	return triggers, rows.Err()
+}
+
+// routineDefinition returns the CREATE statement of a procedure or function.
+// It is empty when the user may not see the body.
+func (s *source) routineDefinition(db, routineType, name string) (string, error) {
+	stmt := fmt.Sprintf("SHOW CREATE %s %s.%s", routineType, quoteIdentifier(db), quoteIdentifier(name))
+	rows, err := s.db.Query(stmt)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", err
+	}
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return "", err
+		}
+		return "", sql.ErrNoRows
+	}
+
+	values := make([]sql.NullString, len(columns))
+	scanArgs := make([]any, len(values))
+	for i := range values {
+		scanArgs[i] = &values[i]
+	}
+	if err := rows.Scan(scanArgs...); err != nil {
+		return "", err
+	}
+
+	for i, col := range columns {
+		if strings.HasPrefix(col, "Create ") {
+			return values[i].String, nil
+		}
+	}
+	return "", fmt.Errorf("%s returned no definition column", stmt)
+}
+
+// routineView shows the definition of one stored procedure or function.
+func (app *application) routineView(w http.ResponseWriter, r *http.Request) {
+	dbName := r.URL.Query().Get("db")
+	name := r.URL.Query().Get("name")
+	routineType := strings.ToUpper(r.URL.Query().Get("type"))
+
+	if dbName == "" || name == "" || (routineType != "PROCEDURE" && routineType != "FUNCTION") {
+		app.notFound(w)
+		return
+	}
+
+	src, ok := app.source(r)
+	if !ok {
+		app.notFound(w)
+		return
+	}
+
+	definition, err := src.routineDefinition(dbName, routineType, name)
+	if err != nil {
+		var mysqlErr *mysqlDriver.MySQLError
+		if errors.Is(err, sql.ErrNoRows) || (errors.As(err, &mysqlErr) && (mysqlErr.Number == erBadDB || mysqlErr.Number == erSPDoesNotExist)) {
+			app.notFound(w)
+			return
+		}
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(w, r)
+	data.Entry = &types.Entry{Title: dbName, Connection: src.name}
+	data.Routine = &types.Routine{Name: name, Type: routineType, Definition: definition}
+	data.Breadcrumbs = append(app.breadcrumbs(src.name, dbName, ""), types.Crumb{Label: name})
+	app.render(w, http.StatusOK, "routine.tmpl", data)
+}
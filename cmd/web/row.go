@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"sequelscope.jonnevuorela.com/types"
+)
+
+var (
+	errNoRecord     = errors.New("no matching row")
+	errNoPrimaryKey = errors.New("table has no primary key")
+	errBadRowKey    = errors.New("malformed row key")
+)
+
+// encodeRowKey turns the primary key values of a row into the pk parameter
+// used to address it. Single-column keys are passed as is and composite keys
+// as a JSON array of strings.
+func encodeRowKey(values []string) string {
+	if len(values) == 1 {
+		return values[0]
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(values)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// decodeRowKey reverses encodeRowKey for a key of n columns.
+func decodeRowKey(key string, n int) ([]string, error) {
+	if n == 1 {
+		return []string{key}, nil
+	}
+	var values []string
+	if err := json.Unmarshal([]byte(key), &values); err != nil || len(values) != n {
+		return nil, errBadRowKey
+	}
+	return values, nil
+}
+
+// keyCondition matches the columns in keys against one placeholder each.
+func keyCondition(keys []string) string {
+	conds := make([]string, len(keys))
+	for i, key := range keys {
+		conds[i] = quoteIdentifier(key) + " = ?"
+	}
+	return strings.Join(conds, " AND ")
+}
+
+// keyArgs converts decoded key values to query arguments.
+func keyArgs(values []string) []any {
+	args := make([]any, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return args
+}
+
+// fetchedRow is a single row read by primary key together with the table's
+// column metadata.
+type fetchedRow struct {
+	names   []string
+	values  []sql.RawBytes
+	columns []types.Column
+}
+
+// fetchRow reads the row of db.table whose primary key equals pk, as encoded
+// by encodeRowKey.
+func (s *source) fetchRow(db, table, pk string) (*fetchedRow, error) {
+	columns, err := s.tableColumns(db, table)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errNoRecord, err)
+	}
+
+	keys := primaryKeys(columns)
+	if len(keys) == 0 {
+		return nil, errNoPrimaryKey
+	}
+	keyValues, err := decodeRowKey(pk, len(keys))
+	if err != nil {
+		return nil, err
+	}
+
+	stmt := fmt.Sprintf("SELECT * FROM %s.%s WHERE %s LIMIT 1",
+		quoteIdentifier(db), quoteIdentifier(table), keyCondition(keys))
+	rows, err := s.db.Query(stmt, keyArgs(keyValues)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, errNoRecord
+	}
+
+	values := make([]sql.RawBytes, len(names))
+	scanArgs := make([]interface{}, len(values))
+	for i := range values {
+		scanArgs[i] = &values[i]
+	}
+	if err := rows.Scan(scanArgs...); err != nil {
+		return nil, err
+	}
+
+	// RawBytes are only valid until the next call on rows, so copy them.
+	for i, v := range values {
+		if v != nil {
+			values[i] = append(sql.RawBytes{}, v...)
+		}
+	}
+
+	return &fetchedRow{names: names, values: values, columns: columns}, nil
+}
+
+// isBinaryType reports whether values of columnType are raw bytes that
+// should be written as hex literals.
+func isBinaryType(columnType string) bool {
+	switch baseType(columnType) {
+	case "binary", "varbinary", "tinyblob", "blob", "mediumblob", "longblob", "bit",
+		"geometry", "point", "linestring", "polygon", "multipoint",
+		"multilinestring", "multipolygon", "geometrycollection":
+		return true
+	}
+	return false
+}
+
+// sqlLiteral renders value as a MySQL literal suited to columnType.
+func sqlLiteral(value []byte, columnType string) string {
+	switch {
+	case value == nil:
+		return "NULL"
+	case isNumeric(columnType):
+		return string(value)
+	case isBinaryType(columnType) || !utf8.Valid(value):
+		if len(value) == 0 {
+			return "''"
+		}
+		return "0x" + hex.EncodeToString(value)
+	}
+	return quoteString(string(value))
+}
+
+var stringEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`'`, `\'`,
+	"\x00", `\0`,
+	"\n", `\n`,
+	"\r", `\r`,
+	"\x1a", `\Z`,
+)
+
+// quoteString single-quotes s with MySQL's backslash escapes.
+func quoteString(s string) string {
+	return "'" + stringEscaper.Replace(s) + "'"
+}
+
+// insertStatement builds an INSERT reproducing row in db.table. Values
+// matched by redact are replaced by the redaction placeholder.
+func insertStatement(db, table string, row *fetchedRow, redact *redactor) string {
+	colTypes := columnTypes(row.names, row.columns)
+
+	cols := make([]string, len(row.names))
+	vals := make([]string, len(row.names))
+	for i, name := range row.names {
+		cols[i] = quoteIdentifier(name)
+		if row.values[i] != nil && redact.match(db, table, name) {
+			vals[i] = quoteString(redactedValue)
+			continue
+		}
+		vals[i] = sqlLiteral(row.values[i], colTypes[i])
+	}
+
+	return fmt.Sprintf("INSERT INTO %s.%s (%s) VALUES (%s);\n",
+		quoteIdentifier(db), quoteIdentifier(table),
+		strings.Join(cols, ", "), strings.Join(vals, ", "))
+}
+
+// cellView returns the full value of one cell, for values cut short in the
+// table grid. Text is returned inline and binary values as a download.
+func (app *application) cellView(w http.ResponseWriter, r *http.Request) {
+	dbName := r.URL.Query().Get("db")
+	tableName := r.URL.Query().Get("table")
+	pk := r.URL.Query().Get("pk")
+	colName := r.URL.Query().Get("col")
+
+	if dbName == "" || tableName == "" || pk == "" || colName == "" {
+		app.notFound(w)
+		return
+	}
+
+	src, ok := app.source(r)
+	if !ok {
+		app.notFound(w)
+		return
+	}
+
+	columns, err := src.tableColumns(dbName, tableName)
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+	keys := primaryKeys(columns)
+	if len(keys) == 0 {
+		app.rowError(w, r, errNoPrimaryKey)
+		return
+	}
+	keyValues, err := decodeRowKey(pk, len(keys))
+	if err != nil {
+		app.rowError(w, r, err)
+		return
+	}
+
+	// Only names from the table's own metadata reach the query.
+	var column *types.Column
+	for i := range columns {
+		if columns[i].Field == colName {
+			column = &columns[i]
+			break
+		}
+	}
+	if column == nil {
+		app.notFound(w)
+		return
+	}
+
+	stmt := fmt.Sprintf("SELECT %s FROM %s.%s WHERE %s LIMIT 1",
+		quoteIdentifier(column.Field), quoteIdentifier(dbName), quoteIdentifier(tableName), keyCondition(keys))
+	var value []byte
+	err = src.db.QueryRow(stmt, keyArgs(keyValues)...).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		app.notFound(w)
+		return
+	}
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	switch {
+	case value == nil:
+		value = []byte("NULL")
+	case app.redact.match(dbName, tableName, column.Field):
+		value = []byte(redactedValue)
+	case isBinaryType(column.Type) || !utf8.Valid(value):
+		filename := fmt.Sprintf("%s.%s.%s.%s.bin", dbName, tableName, pk, column.Field)
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+			"filename": filename,
+		}))
+		w.Write(value)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write(value)
+}
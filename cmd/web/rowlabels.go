@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+)
+
+// binlogColumns describes the columns of a binlog table by position.
+type binlogColumns struct {
+	names    []string
+	unsigned []bool
+}
+
+// columnNameCache remembers the columns of binlog tables by table id, so
+// SHOW COLUMNS runs once per table rather than once per event.
+type columnNameCache struct {
+	mu   sync.Mutex
+	byID map[uint64]binlogColumns
+}
+
+func newColumnNameCache() *columnNameCache {
+	return &columnNameCache{byID: make(map[uint64]binlogColumns)}
+}
+
+// reset forgets every table, e.g. after DDL may have changed columns.
+func (c *columnNameCache) reset() {
+	c.mu.Lock()
+	c.byID = make(map[uint64]binlogColumns)
+	c.mu.Unlock()
+}
+
+// rowsAction names the kind of change a rows event records.
+func rowsAction(t replication.EventType) string {
+	switch t {
+	case replication.WRITE_ROWS_EVENTv0, replication.WRITE_ROWS_EVENTv1, replication.WRITE_ROWS_EVENTv2:
+		return "insert"
+	case replication.UPDATE_ROWS_EVENTv0, replication.UPDATE_ROWS_EVENTv1, replication.UPDATE_ROWS_EVENTv2,
+		replication.PARTIAL_UPDATE_ROWS_EVENT:
+		return "update"
+	case replication.DELETE_ROWS_EVENTv0, replication.DELETE_ROWS_EVENTv1, replication.DELETE_ROWS_EVENTv2:
+		return "delete"
+	}
+	return ""
+}
+
+// binlogColumns returns the column names and signedness of the event's
+// table. Servers with binlog_row_metadata=FULL put the names in the table map
+// event, and MINIMAL already includes signedness; anything missing is looked
+// up with SHOW COLUMNS and cached by table id.
+func (app *application) binlogColumns(table *replication.TableMapEvent) binlogColumns {
+	names := table.ColumnNameString()
+	unsignedMap := table.UnsignedMap()
+	if len(names) > 0 && unsignedMap != nil {
+		cols := binlogColumns{names: names, unsigned: make([]bool, len(names))}
+		for i := range cols.unsigned {
+			cols.unsigned[i] = unsignedMap[i]
+		}
+		return cols
+	}
+
+	app.rowColumns.mu.Lock()
+	cols, ok := app.rowColumns.byID[table.TableID]
+	app.rowColumns.mu.Unlock()
+	if ok {
+		return cols
+	}
+
+	columns, err := app.binlogSource.tableColumns(string(table.Schema), string(table.Table))
+	if err != nil {
+		app.errorLog.Printf("Looking up columns of %s.%s failed: %v", table.Schema, table.Table, err)
+		return binlogColumns{names: names}
+	}
+	cols = binlogColumns{names: make([]string, len(columns)), unsigned: make([]bool, len(columns))}
+	for i, col := range columns {
+		cols.names[i] = col.Field
+		cols.unsigned[i] = strings.Contains(strings.ToLower(col.Type), "unsigned")
+	}
+
+	app.rowColumns.mu.Lock()
+	app.rowColumns.byID[table.TableID] = cols
+	app.rowColumns.mu.Unlock()
+	return cols
+}
+
+// integerValue corrects a decoded binlog integer for an unsigned column,
+// since go-mysql always decodes integers as signed, and returns 64-bit values
+// as decimal strings because JSON numbers lose precision beyond 2^53.
+func integerValue(v any, columnType byte, unsigned bool) any {
+	switch n := v.(type) {
+	case int8:
+		if unsigned {
+			return uint8(n)
+		}
+	case int16:
+		if unsigned {
+			return uint16(n)
+		}
+	case int32:
+		if unsigned {
+			// MEDIUMINT is sign-extended from 24 bits.
+			if columnType == mysql.MYSQL_TYPE_INT24 {
+				return uint32(n) & 0xFFFFFF
+			}
+			return uint32(n)
+		}
+	case int64:
+		if unsigned {
+			return strconv.FormatUint(uint64(n), 10)
+		}
+		return strconv.FormatInt(n, 10)
+	}
+	return v
+}
+
+// labelRows turns the positional values of a rows event into maps keyed by
+// table.column. Columns whose name is unknown, e.g. because the table changed
+// since it was looked up, are labeled by position. Redacted values are masked.
+// For updates, rows alternate between the before and after images. Only the
+// first n rows are labeled.
+func (app *application) labelRows(e *replication.RowsEvent, n int) []map[string]any {
+	db, table := string(e.Table.Schema), string(e.Table.Table)
+	cols := app.binlogColumns(e.Table)
+	names := cols.names
+	if len(names) != int(e.ColumnCount) {
+		names = nil
+	}
+
+	labeled := make([]map[string]any, n)
+	for i, row := range e.Rows[:n] {
+		m := make(map[string]any, len(row))
+		for j, v := range row {
+			name := fmt.Sprintf("@%d", j+1)
+			if j < len(names) {
+				name = names[j]
+			}
+			switch {
+			case v == nil:
+			case app.redact.match(db, table, name):
+				v = redactedValue
+			default:
+				if b, ok := v.([]byte); ok {
+					v = formatCell(b)
+				} else {
+					var columnType byte
+					if j < len(e.Table.ColumnType) {
+						columnType = e.Table.ColumnType[j]
+					}
+					unsigned := names != nil && j < len(cols.unsigned) && cols.unsigned[j]
+					v = integerValue(v, columnType, unsigned)
+				}
+			}
+			m[table+"."+name] = v
+		}
+		labeled[i] = m
+	}
+	return labeled
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+)
+
+func TestIntegerValue(t *testing.T) {
+	tests := []struct {
+		name       string
+		value      any
+		columnType byte
+		unsigned   bool
+		want       any
+	}{
+		{"BIGINT UNSIGNED max", int64(-1), mysql.MYSQL_TYPE_LONGLONG, true, "18446744073709551615"},
+		{"BIGINT signed", int64(-1), mysql.MYSQL_TYPE_LONGLONG, false, "-1"},
+		{"BIGINT beyond 2^53", int64(9007199254740993), mysql.MYSQL_TYPE_LONGLONG, false, "9007199254740993"},
+		{"MEDIUMINT UNSIGNED max", int32(-1), mysql.MYSQL_TYPE_INT24, true, uint32(16777215)},
+		{"MEDIUMINT UNSIGNED high bit", int32(-8388608), mysql.MYSQL_TYPE_INT24, true, uint32(8388608)},
+		{"MEDIUMINT signed", int32(-1), mysql.MYSQL_TYPE_INT24, false, int32(-1)},
+		{"TINYINT UNSIGNED", int8(-1), mysql.MYSQL_TYPE_TINY, true, uint8(255)},
+		{"TINYINT signed", int8(-1), mysql.MYSQL_TYPE_TINY, false, int8(-1)},
+		{"SMALLINT UNSIGNED", int16(-2), mysql.MYSQL_TYPE_SHORT, true, uint16(65534)},
+		{"INT UNSIGNED", int32(-1), mysql.MYSQL_TYPE_LONG, true, uint32(4294967295)},
+		{"INT signed", int32(-5), mysql.MYSQL_TYPE_LONG, false, int32(-5)},
+		{"Not an integer", "abc", mysql.MYSQL_TYPE_VARCHAR, true, "abc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := integerValue(tt.value, tt.columnType, tt.unsigned)
+			if got != tt.want {
+				t.Errorf("integerValue(%v, %d, %t) = %#v; want %#v", tt.value, tt.columnType, tt.unsigned, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,627 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"sequelscope.jonnevuorela.com/types"
+)
+
+// likeEscaper escapes LIKE wildcards with '!' rather than a backslash, which
+// NO_BACKSLASH_ESCAPES would turn into a literal character.
+var likeEscaper = strings.NewReplacer(`!`, `!!`, `%`, `!%`, `_`, `!_`)
+
+// tableList lists the tables and views of db with their table type, ordered
+// by name. A non-empty filter keeps only the names containing it.
+func (s *source) tableList(db, filter string) ([]types.Table, error) {
+	stmt := `SELECT TABLE_NAME, TABLE_TYPE FROM information_schema.TABLES
+		WHERE TABLE_SCHEMA = ?`
+	args := []any{db}
+	if filter != "" {
+		stmt += ` AND TABLE_NAME LIKE ? ESCAPE '!'`
+		args = append(args, "%"+likeEscaper.Replace(filter)+"%")
+	}
+	rows, err := s.db.Query(stmt+" ORDER BY TABLE_NAME", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []types.Table
+	for rows.Next() {
+		var t types.Table
+		if err := rows.Scan(&t.TableName, &t.Type); err != nil {
+			return nil, err
+		}
+		tables = append(tables, t)
+	}
+
+	return tables, rows.Err()
+}
+
+// tableNames lists the names of the tables and views of db.
+func (s *source) tableNames(db string) ([]string, error) {
+	tables, err := s.tableList(db, "")
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(tables))
+	for i, t := range tables {
+		names[i] = t.TableName
+	}
+	return names, nil
+}
+
+// databaseTables returns the tables of db whose names contain filter, or all
+// of them if filter is empty, with their column definitions.
+func (s *source) databaseTables(db, filter string) ([]types.Table, error) {
+	tables, err := s.tableList(db, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range tables {
+		columns, err := s.tableColumns(db, tables[i].TableName)
+		if err != nil {
+			return nil, err
+		}
+		tables[i].Columns = columns
+	}
+	return tables, nil
+}
+
+// diffSchemas compares the tables of two databases by name and, for tables
+// present in both, their columns' type, nullability and key.
+func diffSchemas(nameA, nameB string, a, b []types.Table) *types.SchemaDiff {
+	diff := &types.SchemaDiff{A: nameA, B: nameB}
+
+	tablesB := make(map[string]types.Table, len(b))
+	for _, t := range b {
+		tablesB[t.TableName] = t
+	}
+	inA := make(map[string]bool, len(a))
+
+	for _, ta := range a {
+		inA[ta.TableName] = true
+		tb, ok := tablesB[ta.TableName]
+		if !ok {
+			diff.OnlyInA = append(diff.OnlyInA, ta.TableName)
+			continue
+		}
+		if cols := diffColumns(ta.Columns, tb.Columns); len(cols) > 0 {
+			diff.Tables = append(diff.Tables, types.TableDiff{
+				TableName: ta.TableName,
+				Columns:   cols,
+			})
+		}
+	}
+	for _, tb := range b {
+		if !inA[tb.TableName] {
+			diff.OnlyInB = append(diff.OnlyInB, tb.TableName)
+		}
+	}
+
+	return diff
+}
+
+func diffColumns(a, b []types.Column) []types.ColumnDiff {
+	colsB := make(map[string]types.Column, len(b))
+	for _, c := range b {
+		colsB[c.Field] = c
+	}
+	inA := make(map[string]bool, len(a))
+
+	var diffs []types.ColumnDiff
+	for _, ca := range a {
+		inA[ca.Field] = true
+		cb, ok := colsB[ca.Field]
+		if !ok {
+			diffs = append(diffs, types.ColumnDiff{Field: ca.Field, A: ca, InA: true})
+			continue
+		}
+		if ca.Type != cb.Type || ca.Null != cb.Null || ca.Key != cb.Key {
+			diffs = append(diffs, types.ColumnDiff{Field: ca.Field, A: ca, B: cb, InA: true, InB: true})
+		}
+	}
+	for _, cb := range b {
+		if !inA[cb.Field] {
+			diffs = append(diffs, types.ColumnDiff{Field: cb.Field, B: cb, InB: true})
+		}
+	}
+
+	return diffs
+}
+
+// showCreateTable returns the CREATE statement for db.table. Views report
+// more columns than tables, so only the second column is kept.
+func (s *source) showCreateTable(db, table string) (string, error) {
+	stmt := fmt.Sprintf("SHOW CREATE TABLE %s.%s", quoteIdentifier(db), quoteIdentifier(table))
+	rows, err := s.db.Query(stmt)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", err
+	}
+	if len(columns) < 2 {
+		return "", fmt.Errorf("unexpected SHOW CREATE TABLE result for %s.%s", db, table)
+	}
+
+	values := make([]sql.RawBytes, len(columns))
+	scanArgs := make([]interface{}, len(values))
+	for i := range values {
+		scanArgs[i] = &values[i]
+	}
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return "", err
+		}
+		return "", sql.ErrNoRows
+	}
+	if err := rows.Scan(scanArgs...); err != nil {
+		return "", err
+	}
+
+	return string(values[1]), nil
+}
+
+// foreignKeyDependencies maps each table in db to the tables of the same
+// database it references through foreign keys.
+func (s *source) foreignKeyDependencies(db string) (map[string][]string, error) {
+	rows, err := s.db.Query(`
+		SELECT DISTINCT TABLE_NAME, REFERENCED_TABLE_NAME
+		FROM information_schema.KEY_COLUMN_USAGE
+		WHERE TABLE_SCHEMA = ? AND REFERENCED_TABLE_SCHEMA = ?`, db, db)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deps := make(map[string][]string)
+	for rows.Next() {
+		var table, referenced string
+		if err := rows.Scan(&table, &referenced); err != nil {
+			return nil, err
+		}
+		if table != referenced {
+			deps[table] = append(deps[table], referenced)
+		}
+	}
+
+	return deps, rows.Err()
+}
+
+// orderByDependencies sorts tables so that referenced tables come before the
+// tables referencing them. Cycles are broken by keeping the original order.
+func orderByDependencies(tables []string, deps map[string][]string) []string {
+	known := make(map[string]bool, len(tables))
+	for _, t := range tables {
+		known[t] = true
+	}
+
+	visited := make(map[string]bool, len(tables))
+	ordered := make([]string, 0, len(tables))
+
+	var visit func(string)
+	visit = func(t string) {
+		if visited[t] {
+			return
+		}
+		visited[t] = true
+		for _, dep := range deps[t] {
+			if known[dep] {
+				visit(dep)
+			}
+		}
+		ordered = append(ordered, t)
+	}
+
+	for _, t := range tables {
+		visit(t)
+	}
+	return ordered
+}
+
+// rowCount returns the number of rows in db.table. Tables whose estimated
+// size exceeds exactThreshold report the information_schema
+// estimate instead, with approximate set.
+func (s *source) rowCount(db, table string, exactThreshold int64) (int, bool, error) {
+	var estimate sql.NullInt64
+	err := s.db.QueryRow(`
+		SELECT TABLE_ROWS FROM information_schema.TABLES
+		WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ?`, db, table).Scan(&estimate)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, false, err
+	}
+
+	if estimate.Valid && estimate.Int64 > exactThreshold {
+		return int(estimate.Int64), true, nil
+	}
+
+	var count int
+	stmt := fmt.Sprintf("SELECT COUNT(*) FROM %s.%s", quoteIdentifier(db), quoteIdentifier(table))
+	if err := s.db.QueryRow(stmt).Scan(&count); err != nil {
+		return 0, false, err
+	}
+	return count, false, nil
+}
+
+// tableColumns returns the column definitions of db.table as reported by
+// SHOW COLUMNS.
+func (s *source) tableColumns(db, table string) ([]types.Column, error) {
+	stmt := fmt.Sprintf("SHOW COLUMNS FROM %s.%s", quoteIdentifier(db), quoteIdentifier(table))
+	rows, err := s.db.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Servers and SHOW FULL COLUMNS differ in which columns they return, so
+	// fields are matched by header name and unknown ones are ignored.
+	headers, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	if !slices.ContainsFunc(headers, func(h string) bool { return strings.EqualFold(h, "Field") }) {
+		return nil, fmt.Errorf("SHOW COLUMNS returned no Field column: %v", headers)
+	}
+
+	values := make([]sql.NullString, len(headers))
+	scanArgs := make([]any, len(values))
+	for i := range values {
+		scanArgs[i] = &values[i]
+	}
+
+	var columns []types.Column
+	for rows.Next() {
+		if err := rows.Scan(scanArgs...); err != nil {
+			return nil, err
+		}
+		var col types.Column
+		for i, h := range headers {
+			switch strings.ToLower(h) {
+			case "field":
+				col.Field = values[i].String
+			case "type":
+				col.Type = values[i].String
+			case "null":
+				col.Null = values[i].String
+			case "key":
+				col.Key = values[i].String
+			case "default":
+				col.Default = values[i]
+			case "extra":
+				col.Extra = values[i].String
+			}
+		}
+		columns = append(columns, col)
+	}
+
+	return columns, rows.Err()
+}
+
+// columnTypes returns the declared type of each named column, in order.
+// Columns missing from the schema get an empty type.
+func columnTypes(names []string, columns []types.Column) []string {
+	byName := make(map[string]string, len(columns))
+	for _, col := range columns {
+		byName[col.Field] = col.Type
+	}
+
+	out := make([]string, len(names))
+	for i, name := range names {
+		out[i] = byName[name]
+	}
+	return out
+}
+
+// tableIndexes returns the indexes of db.table as reported by SHOW INDEX.
+// Columns are read by name because their number differs between servers.
+func (s *source) tableIndexes(db, table string) ([]types.Index, error) {
+	stmt := fmt.Sprintf("SHOW INDEX FROM %s.%s", quoteIdentifier(db), quoteIdentifier(table))
+	rows, err := s.db.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]sql.RawBytes, len(columns))
+	scanArgs := make([]interface{}, len(values))
+	for i := range values {
+		scanArgs[i] = &values[i]
+	}
+
+	var indexes []types.Index
+	byName := make(map[string]int)
+	for rows.Next() {
+		if err := rows.Scan(scanArgs...); err != nil {
+			return nil, err
+		}
+
+		var name, column string
+		var nonUnique bool
+		var cardinality int64
+		for i, col := range columns {
+			switch col {
+			case "Key_name":
+				name = string(values[i])
+			case "Column_name":
+				column = string(values[i])
+			case "Non_unique":
+				nonUnique = string(values[i]) != "0"
+			case "Cardinality":
+				cardinality, _ = strconv.ParseInt(string(values[i]), 10, 64)
+			}
+		}
+
+		i, ok := byName[name]
+		if !ok {
+			i = len(indexes)
+			byName[name] = i
+			indexes = append(indexes, types.Index{
+				Name:        name,
+				Unique:      !nonUnique,
+				Cardinality: cardinality,
+			})
+		}
+		indexes[i].Columns = append(indexes[i].Columns, column)
+		if cardinality > indexes[i].Cardinality {
+			indexes[i].Cardinality = cardinality
+		}
+	}
+
+	return indexes, rows.Err()
+}
+
+// primaryKeys returns the primary key columns in table order, or nil if the
+// table has no primary key.
+func primaryKeys(columns []types.Column) []string {
+	var keys []string
+	for _, col := range columns {
+		if col.Key == "PRI" {
+			keys = append(keys, col.Field)
+		}
+	}
+	return keys
+}
+
+// enumValues parses the allowed values out of an ENUM or SET column type
+// such as enum('a','b'), where a quote inside a value is written twice.
+// Other types return nil.
+func enumValues(columnType string) []string {
+	kind := baseType(columnType)
+	if kind != "enum" && kind != "set" {
+		return nil
+	}
+	start := strings.IndexByte(columnType, '(')
+	end := strings.LastIndexByte(columnType, ')')
+	if start < 0 || end < start {
+		return nil
+	}
+
+	var (
+		values []string
+		value  strings.Builder
+		quoted bool
+	)
+	list := columnType[start+1 : end]
+	for i := 0; i < len(list); i++ {
+		c := list[i]
+		switch {
+		case !quoted && c == '\'':
+			quoted = true
+			value.Reset()
+		case quoted && c == '\'' && i+1 < len(list) && list[i+1] == '\'':
+			value.WriteByte(c)
+			i++
+		case quoted && c == '\'':
+			quoted = false
+			values = append(values, value.String())
+		case quoted:
+			value.WriteByte(c)
+		}
+	}
+	return values
+}
+
+// isSpatial reports whether columnType holds geometry, which the server
+// returns as WKB.
+func isSpatial(columnType string) bool {
+	switch baseType(columnType) {
+	case "geometry", "point", "linestring", "polygon", "multipoint",
+		"multilinestring", "multipolygon", "geometrycollection", "geomcollection":
+		return true
+	}
+	return false
+}
+
+// displayExpression returns the function that renders a column of
+// columnType readably, as a format taking the quoted column, or "" to read
+// it as stored. Geometry becomes WKT and fixed-size binary values such as
+// UUIDs become hex; blobs are left to formatCell's preview, since they can
+// be large. MariaDB's JSON is a LONGTEXT alias, so only MySQL's is
+// reindented.
+func displayExpression(columnType string, v serverVersion) string {
+	switch {
+	case isSpatial(columnType):
+		return "ST_AsText(%s)"
+	case baseType(columnType) == "binary", baseType(columnType) == "varbinary":
+		return "HEX(%s)"
+	case isJSON(columnType) && !v.mariaDB && v.atLeast(5, 7, 22):
+		return "JSON_PRETTY(%s)"
+	}
+	return ""
+}
+
+// selectExpressions builds the SELECT list reading the named columns, each
+// wrapped in its displayExpression and keeping its column's name. Columns
+// in raw are read as stored.
+func selectExpressions(names []string, columns []types.Column, v serverVersion, raw []string) string {
+	colTypes := columnTypes(names, columns)
+	exprs := make([]string, len(names))
+	for i, name := range names {
+		col := quoteIdentifier(name)
+		if format := displayExpression(colTypes[i], v); format != "" && !slices.Contains(raw, name) {
+			col = fmt.Sprintf(format, col) + " AS " + col
+		}
+		exprs[i] = col
+	}
+	return strings.Join(exprs, ", ")
+}
+
+// selectedColumns resolves the comma-separated column lists in cols against
+// the table's schema. The result is in table order and always includes the
+// primary key, so rows stay addressable. No lists means every column and
+// returns nil; naming a column the table doesn't have returns false.
+func selectedColumns(cols []string, columns []types.Column) ([]string, bool) {
+	var names []string
+	for _, list := range cols {
+		names = append(names, splitList(list)...)
+	}
+	if len(names) == 0 {
+		return nil, true
+	}
+
+	for _, name := range names {
+		if !slices.ContainsFunc(columns, func(c types.Column) bool { return c.Field == name }) {
+			return nil, false
+		}
+	}
+
+	var selected []string
+	for _, col := range columns {
+		if col.Key == "PRI" || slices.Contains(names, col.Field) {
+			selected = append(selected, col.Field)
+		}
+	}
+	return selected, true
+}
+
+// columnCharsets returns the character set of each text column of db.table.
+// Non-text columns have no entry.
+func (s *source) columnCharsets(db, table string) (map[string]string, error) {
+	rows, err := s.db.Query(`SELECT COLUMN_NAME, CHARACTER_SET_NAME
+		FROM information_schema.COLUMNS
+		WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? AND CHARACTER_SET_NAME IS NOT NULL`, db, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	charsets := make(map[string]string)
+	for rows.Next() {
+		var name, charset string
+		if err := rows.Scan(&name, &charset); err != nil {
+			return nil, err
+		}
+		charsets[name] = charset
+	}
+	return charsets, rows.Err()
+}
+
+// foreignKeys returns the foreign keys of every table in db, keyed by table.
+func (s *source) foreignKeys(db string) (map[string][]types.ForeignKey, error) {
+	rows, err := s.db.Query(`
+		SELECT TABLE_NAME, CONSTRAINT_NAME, COLUMN_NAME,
+			REFERENCED_TABLE_NAME, REFERENCED_COLUMN_NAME
+		FROM information_schema.KEY_COLUMN_USAGE
+		WHERE TABLE_SCHEMA = ? AND REFERENCED_TABLE_NAME IS NOT NULL
+		ORDER BY TABLE_NAME, CONSTRAINT_NAME, ORDINAL_POSITION`, db)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make(map[string][]types.ForeignKey)
+	for rows.Next() {
+		var table, name, column, refTable, refColumn string
+		if err := rows.Scan(&table, &name, &column, &refTable, &refColumn); err != nil {
+			return nil, err
+		}
+		fks := keys[table]
+		if len(fks) == 0 || fks[len(fks)-1].Name != name {
+			fks = append(fks, types.ForeignKey{Name: name, ReferencedTable: refTable})
+		}
+		fk := &fks[len(fks)-1]
+		fk.Columns = append(fk.Columns, column)
+		fk.ReferencedColumns = append(fk.ReferencedColumns, refColumn)
+		keys[table] = fks
+	}
+	return keys, rows.Err()
+}
+
+// schemaColumn converts a SHOW COLUMNS row to its JSON form.
+func schemaColumn(c types.Column) types.SchemaColumn {
+	col := types.SchemaColumn{
+		Field:  c.Field,
+		Type:   c.Type,
+		Null:   c.Null == "YES",
+		Key:    c.Key,
+		Extra:  c.Extra,
+		Values: enumValues(c.Type),
+	}
+	if c.Default.Valid {
+		def := c.Default.String
+		col.Default = &def
+	}
+	return col
+}
+
+// schemaDocument gathers the tables, columns, indexes and foreign keys of db.
+func (s *source) schemaDocument(db string) (*types.SchemaDocument, error) {
+	tables, err := s.databaseTables(db, "")
+	if err != nil {
+		return nil, err
+	}
+	fks, err := s.foreignKeys(db)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &types.SchemaDocument{
+		Connection:  s.name,
+		Database:    db,
+		GeneratedAt: time.Now().UTC(),
+		Tables:      []types.SchemaTable{},
+	}
+	for _, t := range tables {
+		st := types.SchemaTable{
+			Name:        t.TableName,
+			Type:        t.Type,
+			Columns:     []types.SchemaColumn{},
+			Indexes:     []types.SchemaIndex{},
+			ForeignKeys: fks[t.TableName],
+		}
+		if st.ForeignKeys == nil {
+			st.ForeignKeys = []types.ForeignKey{}
+		}
+		for _, c := range t.Columns {
+			st.Columns = append(st.Columns, schemaColumn(c))
+		}
+
+		indexes, err := s.tableIndexes(db, t.TableName)
+		if err != nil {
+			return nil, err
+		}
+		for _, idx := range indexes {
+			st.Indexes = append(st.Indexes, types.SchemaIndex{Name: idx.Name, Columns: idx.Columns, Unique: idx.Unique})
+		}
+		doc.Tables = append(doc.Tables, st)
+	}
+	return doc, nil
+}
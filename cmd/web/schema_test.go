@@ -0,0 +1,25 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEnumValues(t *testing.T) {
+	tests := []struct {
+		columnType string
+		want       []string
+	}{
+		{"enum('a','b','c')", []string{"a", "b", "c"}},
+		{"set('x','it''s','a,b')", []string{"x", "it's", "a,b"}},
+		{"ENUM('')", []string{""}},
+		{"enum('(',')') NOT NULL", []string{"(", ")"}},
+		{"varchar(10)", nil},
+	}
+
+	for _, tt := range tests {
+		if got := enumValues(tt.columnType); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("enumValues(%q) = %q; want %q", tt.columnType, got, tt.want)
+		}
+	}
+}
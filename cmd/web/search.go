@@ -0,0 +1,100 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"sequelscope.jonnevuorela.com/types"
+)
+
+type searchResult struct {
+	Kind       string `json:"kind"`
+	Connection string `json:"connection"`
+	Database   string `json:"database"`
+	Table      string `json:"table,omitempty"`
+	URL        string `json:"url"`
+	Score      int    `json:"score"`
+}
+
+// maxSearchResults caps how many matches /search returns.
+const maxSearchResults = 20
+
+// searchScore rates how well name matches query. Exact matches rank above
+// prefixes, prefixes above substrings and substrings above fuzzy
+// subsequence matches. Zero means no match.
+func searchScore(query, name string) int {
+	q, n := strings.ToLower(query), strings.ToLower(name)
+	switch {
+	case q == n:
+		return 100
+	case strings.HasPrefix(n, q):
+		return 80
+	case strings.Contains(n, q):
+		return 60
+	}
+
+	// Fuzzy: every query rune appears in order; tighter matches score higher.
+	gaps, pos := 0, 0
+	for _, r := range q {
+		i := strings.IndexRune(n[pos:], r)
+		if i < 0 {
+			return 0
+		}
+		gaps += i
+		pos += i + len(string(r))
+	}
+	score := 40 - gaps
+	if score < 1 {
+		score = 1
+	}
+	return score
+}
+
+// searchMetadata ranks databases and tables against query. Table hits get a
+// small bonus so exact table names win over databases of the same name.
+func searchMetadata(query string, entries []*types.Entry, schemas map[int][]string) []searchResult {
+	var results []searchResult
+	for _, entry := range entries {
+		db := entry.Title
+		if score := searchScore(query, db); score > 0 {
+			results = append(results, searchResult{
+				Kind:       "database",
+				Connection: entry.Connection,
+				Database:   db,
+				URL:        "/entry/view/" + strconv.Itoa(entry.Id),
+				Score:      score,
+			})
+		}
+		for _, table := range schemas[entry.Id] {
+			if score := searchScore(query, table); score > 0 {
+				results = append(results, searchResult{
+					Kind:       "table",
+					Connection: entry.Connection,
+					Database:   db,
+					Table:      table,
+					URL:        tableURL(entry.Connection, db, table),
+					Score:      score + 5,
+				})
+			}
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		if results[i].Connection != results[j].Connection {
+			return results[i].Connection < results[j].Connection
+		}
+		if results[i].Database != results[j].Database {
+			return results[i].Database < results[j].Database
+		}
+		return results[i].Table < results[j].Table
+	})
+
+	if len(results) > maxSearchResults {
+		results = results[:maxSearchResults]
+	}
+	return results
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// shutdownTimeout bounds how long in-flight requests get to finish once a
+// shutdown signal arrives.
+const shutdownTimeout = 10 * time.Second
+
+// serve runs srv on ln until SIGINT or SIGTERM, then shuts down gracefully:
+// in-flight requests are drained, the binlog watcher is stopped and
+// websocket clients are disconnected.
+func (app *application) serve(srv *http.Server, ln net.Listener) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.Serve(ln)
+	}()
+
+	select {
+	case err := <-errs:
+		app.stopBinlogWatcher()
+		return err
+	case <-ctx.Done():
+	}
+
+	app.infoLog.Print("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	err := srv.Shutdown(shutdownCtx)
+	app.stopBinlogWatcher()
+	app.closeClients()
+
+	if errors.Is(err, http.ErrServerClosed) {
+		err = nil
+	}
+	return err
+}
+
+// closeClients disconnects every websocket client. Hijacked connections
+// aren't tracked by http.Server, so Shutdown leaves them open. Each client
+// is first told the server is going away, so it can back off and reconnect
+// rather than treat the drop as a network error. The goodbyes go out
+// concurrently, outside the clients lock, so stuck clients cost one
+// byeTimeout in total.
+func (app *application) closeClients() {
+	app.clientsMux.Lock()
+	clients := app.clients
+	app.clients = make(map[*websocket.Conn]*wsClient)
+	app.clientsMux.Unlock()
+
+	var wg sync.WaitGroup
+	for conn, client := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client.sayGoodbye(conn, "shutdown")
+			conn.Close()
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"strings"
+	"sync"
+	"time"
+
+	"sequelscope.jonnevuorela.com/types"
+)
+
+// maxSizeSamples is how many size samples are kept per database.
+const maxSizeSamples = 96
+
+// sizeHistory keeps recent size samples per database, keyed by sizeKey.
+type sizeHistory struct {
+	mu      sync.RWMutex
+	samples map[string][]types.SizeSample
+}
+
+func newSizeHistory() *sizeHistory {
+	return &sizeHistory{samples: make(map[string][]types.SizeSample)}
+}
+
+func sizeKey(conn, db string) string {
+	return conn + "\x00" + db
+}
+
+func (h *sizeHistory) add(key string, sample types.SizeSample) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	samples := append(h.samples[key], sample)
+	if len(samples) > maxSizeSamples {
+		samples = samples[len(samples)-maxSizeSamples:]
+	}
+	h.samples[key] = samples
+}
+
+// get returns a copy of the samples recorded for key, oldest first.
+func (h *sizeHistory) get(key string) []types.SizeSample {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return append([]types.SizeSample(nil), h.samples[key]...)
+}
+
+// databaseSizes returns the data plus index size in bytes of every database
+// on the server.
+func (s *source) databaseSizes() (map[string]int64, error) {
+	rows, err := s.db.Query(`SELECT TABLE_SCHEMA, COALESCE(SUM(DATA_LENGTH + INDEX_LENGTH), 0)
+		FROM information_schema.TABLES GROUP BY TABLE_SCHEMA`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sizes := make(map[string]int64)
+	for rows.Next() {
+		var db string
+		var size int64
+		if err := rows.Scan(&db, &size); err != nil {
+			return nil, err
+		}
+		sizes[db] = size
+	}
+	return sizes, rows.Err()
+}
+
+// sampleSizes records the size of every browsable database once per
+// interval, starting immediately.
+func (app *application) sampleSizes(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		now := time.Now()
+		for _, src := range app.sources {
+			sizes, err := src.databaseSizes()
+			if err != nil {
+				app.errorLog.Printf("Sampling database sizes on %s failed: %v", src.name, err)
+				continue
+			}
+			for db, size := range sizes {
+				if app.databases.allow(db) {
+					app.sizes.add(sizeKey(src.name, db), types.SizeSample{Time: now, Bytes: size})
+				}
+			}
+		}
+		<-ticker.C
+	}
+}
+
+// formatBytes renders n with a binary unit, e.g. "1.5 MiB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// latestSize formats the most recent of samples.
+func latestSize(samples []types.SizeSample) string {
+	if len(samples) == 0 {
+		return ""
+	}
+	return formatBytes(samples[len(samples)-1].Bytes)
+}
+
+// sparkline draws samples as a small inline SVG line chart. Its markup is
+// built only from numbers, so it is safe to return as template.HTML.
+func sparkline(samples []types.SizeSample) template.HTML {
+	if len(samples) < 2 {
+		return ""
+	}
+	const width, height = 100, 20
+
+	lo, hi := samples[0].Bytes, samples[0].Bytes
+	for _, s := range samples {
+		lo, hi = min(lo, s.Bytes), max(hi, s.Bytes)
+	}
+
+	points := make([]string, len(samples))
+	for i, s := range samples {
+		x := float64(i) * width / float64(len(samples)-1)
+		y := float64(height) / 2
+		if hi > lo {
+			y = height - float64(s.Bytes-lo)*height/float64(hi-lo)
+		}
+		points[i] = fmt.Sprintf("%.1f,%.1f", x, y)
+	}
+
+	return template.HTML(fmt.Sprintf(
+		`<svg class="sparkline" width="%d" height="%d" viewBox="0 -1 %d %d"><title>%s</title><polyline fill="none" stroke="currentColor" points="%s"/></svg>`,
+		width, height, width, height+2, template.HTMLEscapeString(formatBytes(lo)+" to "+formatBytes(hi)), strings.Join(points, " ")))
+}
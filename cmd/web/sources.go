@@ -0,0 +1,260 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	mysqlDriver "github.com/go-sql-driver/mysql"
+)
+
+// erBadDB is the MySQL error number for an unknown database.
+const erBadDB = 1049
+
+// erNoSuchTable is the MySQL error number for an unknown table.
+const erNoSuchTable = 1146
+
+// erSPDoesNotExist is the MySQL error number for an unknown stored routine.
+const erSPDoesNotExist = 1305
+
+// defaultSourceName names a connection given without a name= prefix.
+const defaultSourceName = "default"
+
+// source is one named MySQL server connection.
+type source struct {
+	name    string
+	dsn     string
+	db      *sql.DB
+	version serverVersion
+	// loc is the time zone the driver assumes for DATETIME values, from
+	// the DSN's loc parameter.
+	loc *time.Location
+}
+
+// dsnList collects repeated -dsn flags. Each value is either a bare DSN or
+// name=DSN.
+type dsnList []string
+
+func (l *dsnList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *dsnList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// splitNamedDSN separates an optional "name=" prefix from a DSN. The prefix
+// must come before any of the DSN's own ':', '@' or '/' characters.
+func splitNamedDSN(value string) (string, string) {
+	eq := strings.Index(value, "=")
+	if eq > 0 {
+		other := strings.IndexAny(value, ":@/")
+		if other < 0 || eq < other {
+			return value[:eq], value[eq+1:]
+		}
+	}
+	return defaultSourceName, value
+}
+
+// openSources connects to every DSN and checks it is reachable, retrying
+// each for up to connectTimeout.
+func openSources(dsns []string, session sessionSettings, connectTimeout time.Duration, logger *log.Logger) ([]*source, error) {
+	var sources []*source
+	seen := make(map[string]bool)
+	for _, value := range dsns {
+		name, dsn := splitNamedDSN(value)
+		if strings.Contains(name, "/") {
+			return nil, fmt.Errorf("connection name %q must not contain '/'", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate connection name %q", name)
+		}
+		seen[name] = true
+
+		dsn, err := session.apply(dsn)
+		if err != nil {
+			return nil, fmt.Errorf("connection %s: %w", name, err)
+		}
+
+		db, err := sql.Open("mysql", dsn)
+		if err != nil {
+			return nil, err
+		}
+		if err := pingWithRetry(db, name, connectTimeout, logger); err != nil {
+			db.Close()
+			return nil, err
+		}
+
+		version, err := queryServerVersion(db)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("connection %s: %w", name, err)
+		}
+
+		// Reopen with the session made read-only, now that the server
+		// version says what the variable is called.
+		if session.readOnly {
+			db.Close()
+			if dsn, err = session.applyReadOnly(dsn, version); err != nil {
+				return nil, fmt.Errorf("connection %s: %w", name, err)
+			}
+			if db, err = sql.Open("mysql", dsn); err != nil {
+				return nil, err
+			}
+			if err := db.Ping(); err != nil {
+				db.Close()
+				return nil, fmt.Errorf("connection %s: making the session read-only: %w", name, err)
+			}
+		}
+
+		loc := time.UTC
+		if cfg, err := mysqlDriver.ParseDSN(dsn); err == nil && cfg.Loc != nil {
+			loc = cfg.Loc
+		}
+		if zone, ok := session.location(); ok {
+			loc = zone
+		}
+
+		sources = append(sources, &source{
+			name:    name,
+			dsn:     dsn,
+			db:      db,
+			version: version,
+			loc:     loc,
+		})
+	}
+	return sources, nil
+}
+
+// sessionSettings are session variables set on every new connection, so
+// values render the same whatever the server's defaults are.
+type sessionSettings struct {
+	sqlMode  string
+	timeZone string
+	readOnly bool
+}
+
+// apply adds the settings to dsn as system variable parameters, which the
+// driver sets with SET when it opens each connection.
+func (s sessionSettings) apply(dsn string) (string, error) {
+	if s.sqlMode == "" && s.timeZone == "" {
+		return dsn, nil
+	}
+	cfg, err := mysqlDriver.ParseDSN(dsn)
+	if err != nil {
+		return "", err
+	}
+	if cfg.Params == nil {
+		cfg.Params = make(map[string]string)
+	}
+	if s.sqlMode != "" {
+		cfg.Params["sql_mode"] = quoteString(s.sqlMode)
+	}
+	if s.timeZone != "" {
+		cfg.Params["time_zone"] = quoteString(s.timeZone)
+	}
+	return cfg.FormatDSN(), nil
+}
+
+// applyReadOnly adds the variable making transactions read-only to dsn. Its
+// name depends on the server, so it is only known once connected.
+func (s sessionSettings) applyReadOnly(dsn string, v serverVersion) (string, error) {
+	cfg, err := mysqlDriver.ParseDSN(dsn)
+	if err != nil {
+		return "", err
+	}
+	if cfg.Params == nil {
+		cfg.Params = make(map[string]string)
+	}
+	cfg.Params[readOnlyVariable(v)] = "1"
+	return cfg.FormatDSN(), nil
+}
+
+// location returns the Go equivalent of the session time zone, which is
+// what TIMESTAMP values are rendered in. It accepts MySQL's offset form,
+// e.g. +02:00, and named zones; SYSTEM can't be resolved here.
+func (s sessionSettings) location() (*time.Location, bool) {
+	tz := s.timeZone
+	if tz == "" || strings.EqualFold(tz, "SYSTEM") {
+		return nil, false
+	}
+	if t, err := time.Parse("-07:00", tz); err == nil {
+		_, offset := t.Zone()
+		return time.FixedZone(tz, offset), true
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, false
+	}
+	return loc, true
+}
+
+// databases lists the databases on the server.
+func (s *source) databases() ([]string, error) {
+	rows, err := s.db.Query("SHOW DATABASES")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+
+	return names, rows.Err()
+}
+
+// sourceByName returns the connection with the given name. An empty name
+// selects the first connection.
+func (app *application) sourceByName(name string) (*source, bool) {
+	if name == "" {
+		return app.sources[0], true
+	}
+	for _, src := range app.sources {
+		if src.name == name {
+			return src, true
+		}
+	}
+	return nil, false
+}
+
+func (app *application) sourceNames() []string {
+	names := make([]string, len(app.sources))
+	for i, src := range app.sources {
+		names[i] = src.name
+	}
+	return names
+}
+
+// tableURL links to the table view of db.table on the named connection.
+// params are further key, value pairs; pairs with an empty value are left
+// out.
+func tableURL(conn, db, table string, params ...string) string {
+	v := url.Values{}
+	v.Set("db", db)
+	v.Set("table", table)
+	if conn != "" {
+		v.Set("conn", conn)
+	}
+	for i := 0; i+1 < len(params); i += 2 {
+		if params[i+1] != "" {
+			v.Set(params[i], params[i+1])
+		}
+	}
+	return "/entry/view/table?" + v.Encode()
+}
+
+// source returns the connection named by the request's conn parameter.
+func (app *application) source(r *http.Request) (*source, bool) {
+	return app.sourceByName(r.URL.Query().Get("conn"))
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"net/http"
+	"strings"
+)
+
+// staticETags hashes every file in fsys once, keyed by its path.
+func staticETags(fsys fs.FS) (map[string]string, error) {
+	etags := make(map[string]string)
+	err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		b, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(b)
+		etags[path] = `"` + hex.EncodeToString(sum[:16]) + `"`
+		return nil
+	})
+	return etags, err
+}
+
+// cacheStatic sets strong ETags for the embedded static files and answers a
+// matching If-None-Match with 304. Asset URLs aren't versioned, so browsers
+// are told to revalidate on every use rather than keep a stale copy.
+func cacheStatic(etags map[string]string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		etag, ok := etags[strings.TrimPrefix(r.URL.Path, "/")]
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", "no-cache")
+
+		if match := r.Header.Get("If-None-Match"); match != "" {
+			for _, candidate := range strings.Split(match, ",") {
+				if c := strings.TrimSpace(candidate); c == etag || c == "*" {
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// serveStaticFile answers a fixed well-known path such as /favicon.ico with
+// the named file from the static file server.
+func serveStaticFile(name string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r2 := r.Clone(r.Context())
+		r2.URL.Path = "/" + name
+		r2.URL.RawPath = ""
+		next.ServeHTTP(w, r2)
+	})
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// statsWindow is how many one-second samples the events-per-second rate is
+// averaged over.
+const statsWindow = 5
+
+// eventRate counts binlog events so a rolling per-second rate can be derived.
+type eventRate struct {
+	total   atomic.Int64
+	samples [statsWindow]int64
+	next    int
+	last    int64
+}
+
+func (r *eventRate) increment() {
+	r.total.Add(1)
+}
+
+// sample records the events seen since the previous call and returns the
+// average per second over the window. It must be called once per second
+// from a single goroutine.
+func (r *eventRate) sample() float64 {
+	total := r.total.Load()
+	r.samples[r.next%statsWindow] = total - r.last
+	r.next++
+	r.last = total
+
+	n := min(r.next, statsWindow)
+	var sum int64
+	for _, s := range r.samples[:n] {
+		sum += s
+	}
+	return float64(sum) / float64(n)
+}
+
+// broadcastStats sends a stats message with the event rate and client count
+// to every client once per interval.
+func (app *application) broadcastStats(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		eps := app.eventRate.sample()
+
+		clients := app.clientCount()
+		if clients == 0 {
+			continue
+		}
+
+		app.broadcastChange(map[string]any{
+			"type":    "stats",
+			"eps":     eps,
+			"clients": clients,
+		})
+	}
+}
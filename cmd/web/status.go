@@ -0,0 +1,38 @@
+package main
+
+import (
+	"sync"
+
+	"sequelscope.jonnevuorela.com/types"
+)
+
+type watcherState string
+
+const (
+	watcherStarting watcherState = "starting"
+	watcherRunning  watcherState = "running"
+	watcherFailed   watcherState = "failed"
+	watcherDisabled watcherState = "disabled"
+)
+
+// watcherStatus records the current state of the binlog watcher.
+type watcherStatus struct {
+	mu      sync.RWMutex
+	state   watcherState
+	message string
+}
+
+func (s *watcherStatus) set(state watcherState, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.state = state
+	s.message = message
+}
+
+func (s *watcherStatus) get() types.WatcherStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return types.WatcherStatus{State: string(s.state), Message: s.message}
+}
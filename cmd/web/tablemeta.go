@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+
+	"sequelscope.jonnevuorela.com/types"
+)
+
+// tableMeta gathers the columns, row count and indexes of one
+// table for the database view.
+func (app *application) tableMeta(src *source, db string, table types.Table) (*types.TableMeta, error) {
+	meta := &types.TableMeta{
+		Connection: src.name,
+		Database:   db,
+		Table:      table.TableName,
+		Type:       table.Type,
+		Columns:    []types.SchemaColumn{},
+		Indexes:    []types.Index{},
+	}
+
+	columns, err := src.tableColumns(db, table.TableName)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range columns {
+		meta.Columns = append(meta.Columns, schemaColumn(c))
+	}
+
+	meta.EntryCount, meta.EntryCountApprox, err = src.rowCount(db, table.TableName, app.config.exactCountThreshold)
+	if err != nil {
+		return nil, err
+	}
+
+	indexes, err := src.tableIndexes(db, table.TableName)
+	if err != nil {
+		return nil, err
+	}
+	meta.Indexes = append(meta.Indexes, indexes...)
+
+	return meta, nil
+}
+
+// apiTableMeta returns the detail of one table as JSON, loaded lazily by the
+// database view.
+func (app *application) apiTableMeta(w http.ResponseWriter, r *http.Request) {
+	dbName := r.URL.Query().Get("db")
+	tableName := r.URL.Query().Get("table")
+	if dbName == "" || tableName == "" {
+		app.notFound(w)
+		return
+	}
+
+	src, ok := app.source(r)
+	if !ok {
+		app.notFound(w)
+		return
+	}
+
+	// The name also filters the table list, which validates it and gives
+	// us the table type.
+	tables, err := src.tableList(dbName, tableName)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	for _, table := range tables {
+		if table.TableName != tableName {
+			continue
+		}
+		meta, err := app.tableMeta(src, dbName, table)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		app.writeJSON(w, http.StatusOK, meta)
+		return
+	}
+	app.notFound(w)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"database/sql"
+	"math"
+	"strings"
+	"time"
+)
+
+// integerMax returns the largest value an integer column of columnType can
+// hold, or 0 for non-integer types.
+func integerMax(columnType string) float64 {
+	unsigned := strings.Contains(strings.ToLower(columnType), "unsigned")
+	var bits int
+	switch baseType(columnType) {
+	case "tinyint":
+		bits = 8
+	case "smallint":
+		bits = 16
+	case "mediumint":
+		bits = 24
+	case "int", "integer":
+		bits = 32
+	case "bigint":
+		bits = 64
+	default:
+		return 0
+	}
+	if !unsigned {
+		bits--
+	}
+	return math.Exp2(float64(bits)) - 1
+}
+
+// tableStatus is what information_schema.TABLES tells us about a table
+// beyond its name.
+type tableStatus struct {
+	// autoIncrement is the next AUTO_INCREMENT value, 0 if there is none,
+	// and autoIncrementPct how much of the column type's range it uses.
+	autoIncrement    uint64
+	autoIncrementPct float64
+	// updateTime is when the table last changed. It is zero when the
+	// engine doesn't track it, as InnoDB doesn't across restarts.
+	updateTime time.Time
+}
+
+// tableStatuses reads the AUTO_INCREMENT usage and last update time of every
+// table of db with one information_schema query.
+func (s *source) tableStatuses(db string) (map[string]tableStatus, error) {
+	rows, err := s.db.Query(`SELECT t.TABLE_NAME, t.AUTO_INCREMENT, t.UPDATE_TIME, c.COLUMN_TYPE
+		FROM information_schema.TABLES t
+		LEFT JOIN information_schema.COLUMNS c
+		  ON c.TABLE_SCHEMA = t.TABLE_SCHEMA AND c.TABLE_NAME = t.TABLE_NAME
+		 AND c.EXTRA LIKE '%auto_increment%'
+		WHERE t.TABLE_SCHEMA = ?`, db)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	statuses := make(map[string]tableStatus)
+	for rows.Next() {
+		var table string
+		var next sql.Null[uint64]
+		var updated, columnType sql.NullString
+		if err := rows.Scan(&table, &next, &updated, &columnType); err != nil {
+			return nil, err
+		}
+
+		var st tableStatus
+		if next.Valid && columnType.Valid {
+			st.autoIncrement = next.V
+			if limit := integerMax(columnType.String); limit > 0 {
+				st.autoIncrementPct = float64(next.V) / limit * 100
+			}
+		}
+		if updated.Valid {
+			st.updateTime = s.parseServerTime(updated.String)
+		}
+		statuses[table] = st
+	}
+	return statuses, rows.Err()
+}
+
+// parseServerTime parses a DATETIME value as returned by the driver, either
+// as MySQL text in the connection's time zone or, with parseTime=true in the
+// DSN, already formatted as RFC 3339. Unparseable values give the zero time.
+func (s *source) parseServerTime(v string) time.Time {
+	if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+		return t
+	}
+	loc := s.loc
+	if loc == nil {
+		loc = time.UTC
+	}
+	t, _ := time.ParseInLocation("2006-01-02 15:04:05", v, loc)
+	return t
+}
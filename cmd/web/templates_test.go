@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"sequelscope.jonnevuorela.com/types"
+	"sequelscope.jonnevuorela.com/ui"
+)
+
+// renderTable renders the body of table.tmpl for td.
+func renderTable(t *testing.T, td *types.TableData) string {
+	t.Helper()
+
+	cache, err := newTemplateCache(ui.Files, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := &types.TemplateData{
+		Entry: &types.Entry{
+			Title:  td.Database,
+			Tables: []types.Table{{TableName: td.Table}},
+		},
+		TableData: td,
+	}
+
+	// Only the page body: the base layout has script tags of its own.
+	var buf bytes.Buffer
+	if err := cache["table.tmpl"].ExecuteTemplate(&buf, "main", data); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestTableEscapesCells(t *testing.T) {
+	payloads := []string{
+		`<script>alert(1)</script>`,
+		`"><img src=x onerror=alert(1)>`,
+		`https://example.com/<script>alert(1)</script>`,
+		`https://example.com/"><img src=x onerror=alert(1)>`,
+	}
+
+	for _, linkify := range []bool{false, true} {
+		for _, payload := range payloads {
+			td := &types.TableData{
+				Database:    "db",
+				Table:       "t",
+				Columns:     []string{"id", "body"},
+				ColumnTypes: []string{"int", "text"},
+				PrimaryKey:  []string{"id"},
+				RowKeys:     []string{"1"},
+				Rows:        []map[string]string{{"id": "1", "body": payload}},
+				Linkify:     linkify,
+			}
+			out := renderTable(t, td)
+			if !strings.Contains(out, "alert(1)") {
+				t.Fatalf("links=%t: cell value %q not rendered", linkify, payload)
+			}
+			for _, raw := range []string{"<script", "<img"} {
+				if strings.Contains(out, raw) {
+					t.Errorf("links=%t, value %q: output contains raw %s", linkify, payload, raw)
+				}
+			}
+		}
+	}
+}
+
+var hrefRX = regexp.MustCompile(`href="([^"]*)"`)
+
+func TestTableLinksEncodeNames(t *testing.T) {
+	const (
+		db     = "my db"
+		table  = "weird name"
+		column = "a&b#c+d"
+	)
+	td := &types.TableData{
+		Database:        db,
+		Table:           table,
+		Columns:         []string{"id", column},
+		ColumnTypes:     []string{"int", "text"},
+		PrimaryKey:      []string{"id"},
+		RowKeys:         []string{"1"},
+		Rows:            []map[string]string{{"id": "1", column: "x"}},
+		SelectedColumns: "id," + column,
+	}
+	out := renderTable(t, td)
+
+	var displayLinks int
+	for _, m := range hrefRX.FindAllStringSubmatch(out, -1) {
+		u, err := url.Parse(html.UnescapeString(m[1]))
+		if err != nil {
+			t.Errorf("unparseable link %q: %v", m[1], err)
+			continue
+		}
+		q := u.Query()
+		if q.Has("db") && (q.Get("db") != db || q.Get("table") != table) {
+			t.Errorf("link %q has db %q and table %q; want %q and %q", m[1], q.Get("db"), q.Get("table"), db, table)
+		}
+		if q.Has("display") {
+			displayLinks++
+			if got := q.Get("display_col"); got != "id" && got != column {
+				t.Errorf("link %q has display_col %q", m[1], got)
+			}
+			if got := q.Get("cols"); got != td.SelectedColumns {
+				t.Errorf("link %q has cols %q; want %q", m[1], got, td.SelectedColumns)
+			}
+		}
+	}
+	if displayLinks != 6 {
+		t.Errorf("found %d display links; want 6", displayLinks)
+	}
+}
+
+func TestTableSkipsFormattingEncodedCells(t *testing.T) {
+	td := &types.TableData{
+		Database:      "db",
+		Table:         "t",
+		Columns:       []string{"n", "m"},
+		ColumnTypes:   []string{"bigint", "bigint"},
+		Rows:          []map[string]string{{"n": "31323334353637", "m": "1234567"}},
+		DisplayMode:   displayHex,
+		DisplayColumn: "n",
+	}
+	out := renderTable(t, td)
+	if !strings.Contains(out, "31323334353637") {
+		t.Error("hex value of n was reformatted")
+	}
+	if !strings.Contains(out, "1,234,567") {
+		t.Error("m, not shown as hex, lost its number formatting")
+	}
+}
+
+func TestTableLinksKeepFilter(t *testing.T) {
+	td := &types.TableData{
+		Database:     "db",
+		Table:        "t",
+		Columns:      []string{"id", "state"},
+		ColumnTypes:  []string{"int", "enum('on','off')"},
+		PrimaryKey:   []string{"id"},
+		RowKeys:      []string{"1"},
+		Rows:         []map[string]string{{"id": "1", "state": "on"}},
+		EnumOptions:  map[string][]string{"state": {"on", "off"}},
+		FilterColumn: "state",
+		FilterValue:  "on",
+	}
+	out := renderTable(t, td)
+
+	for _, m := range hrefRX.FindAllStringSubmatch(out, -1) {
+		u, err := url.Parse(html.UnescapeString(m[1]))
+		if err != nil || !strings.HasSuffix(u.Path, "/entry/view/table") {
+			continue
+		}
+		if q := u.Query(); q.Get("filter_col") != "state" || q.Get("filter_val") != "on" {
+			t.Errorf("link %q drops the filter", m[1])
+		}
+	}
+	if n := strings.Count(out, `name="filter_col" value="state"`); n < 3 {
+		t.Errorf("found the filter in %d forms; want the seek form, column picker and enum filter", n)
+	}
+}
+
+var loadMoreRX = regexp.MustCompile(`class="load-more" data-url="([^"]*)"`)
+
+func TestLoadMoreKeepsDisplay(t *testing.T) {
+	td := &types.TableData{
+		Database:      "db",
+		Table:         "t",
+		Columns:       []string{"id", "body"},
+		ColumnTypes:   []string{"int", "blob"},
+		PrimaryKey:    []string{"id"},
+		RowKeys:       []string{"1"},
+		Rows:          []map[string]string{{"id": "1", "body": "6869"}},
+		NextCursor:    "1",
+		Limit:         50,
+		DisplayMode:   displayHex,
+		DisplayColumn: "body",
+		Linkify:       true,
+	}
+	out := renderTable(t, td)
+
+	m := loadMoreRX.FindStringSubmatch(out)
+	if m == nil {
+		t.Fatal("no load more button")
+	}
+	u, err := url.Parse(html.UnescapeString(m[1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"limit":       "50",
+		"display":     displayHex,
+		"display_col": "body",
+		"links":       "1",
+		"format":      "rows",
+	}
+	for k, v := range want {
+		if got := u.Query().Get(k); got != v {
+			t.Errorf("load more %s = %q; want %q", k, got, v)
+		}
+	}
+}
+
+func TestTableShowsUncutCells(t *testing.T) {
+	long := strings.Repeat("ä", 40)
+	td := &types.TableData{
+		Database:    "db",
+		Table:       "t",
+		Columns:     []string{"id", "body"},
+		ColumnTypes: []string{"int", "text"},
+		PrimaryKey:  []string{"id"},
+		RowKeys:     []string{"1"},
+		Rows:        []map[string]string{{"id": "1", "body": long}},
+	}
+	out := renderTable(t, td)
+	if !strings.Contains(out, ">"+long+"<") {
+		t.Error("a value under -max-cell-bytes was cut short")
+	}
+}
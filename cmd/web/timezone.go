@@ -0,0 +1,34 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+const mysqlDateTimeLayout = "2006-01-02 15:04:05"
+
+// isDateTime reports whether columnType holds a date and a time of day,
+// the types -display-tz converts.
+func isDateTime(columnType string) bool {
+	switch baseType(columnType) {
+	case "datetime", "timestamp":
+		return true
+	}
+	return false
+}
+
+// convertDateTime reinterprets a MySQL DATETIME/TIMESTAMP string given in
+// from as a time in to, keeping any fractional seconds. Values that don't
+// parse, such as zero dates, are returned unchanged.
+func convertDateTime(s string, from, to *time.Location) string {
+	layout := mysqlDateTimeLayout
+	if dot := strings.IndexByte(s, '.'); dot >= 0 {
+		layout += "." + strings.Repeat("0", len(s)-dot-1)
+	}
+
+	t, err := time.ParseInLocation(layout, s, from)
+	if err != nil {
+		return s
+	}
+	return t.In(to).Format(layout)
+}
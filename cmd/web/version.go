@@ -0,0 +1,186 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// serverVersion describes the MySQL or MariaDB server we're connected to.
+type serverVersion struct {
+	major, minor, patch int
+	mariaDB             bool
+}
+
+// parseServerVersion parses SELECT VERSION() output such as "8.4.0",
+// "8.0.36-log" or "10.11.6-MariaDB-1:10.11.6+maria~ubu2204".
+func parseServerVersion(s string) serverVersion {
+	v := serverVersion{mariaDB: strings.Contains(strings.ToLower(s), "mariadb")}
+
+	numbers := s
+	if i := strings.IndexFunc(numbers, func(r rune) bool {
+		return r != '.' && (r < '0' || r > '9')
+	}); i >= 0 {
+		numbers = numbers[:i]
+	}
+
+	parts := strings.SplitN(numbers, ".", 3)
+	fields := []*int{&v.major, &v.minor, &v.patch}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			break
+		}
+		*fields[i] = n
+	}
+	return v
+}
+
+// atLeast reports whether v is major.minor.patch or newer.
+func (v serverVersion) atLeast(major, minor, patch int) bool {
+	if v.major != major {
+		return v.major > major
+	}
+	if v.minor != minor {
+		return v.minor > minor
+	}
+	return v.patch >= patch
+}
+
+func (v serverVersion) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.major, v.minor, v.patch)
+	if v.mariaDB {
+		s += "-MariaDB"
+	}
+	return s
+}
+
+// binlogStatusStatement picks the statement reporting the current binlog
+// position. MySQL 8.2 replaced SHOW MASTER STATUS with SHOW BINARY LOG
+// STATUS and 8.4 removed the old form.
+func binlogStatusStatement(v serverVersion) string {
+	if !v.mariaDB && v.atLeast(8, 2, 0) {
+		return "SHOW BINARY LOG STATUS"
+	}
+	return "SHOW MASTER STATUS"
+}
+
+func queryServerVersion(db *sql.DB) (serverVersion, error) {
+	var version string
+	if err := db.QueryRow("SELECT VERSION()").Scan(&version); err != nil {
+		return serverVersion{}, err
+	}
+	return parseServerVersion(version), nil
+}
+
+// queryBinlogPosition runs stmt and reads the File and Position columns by
+// name, since the number of columns varies between server versions.
+func queryBinlogPosition(db *sql.DB, stmt string) (string, uint32, error) {
+	rows, err := db.Query(stmt)
+	if err != nil {
+		return "", 0, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", 0, err
+	}
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return "", 0, err
+		}
+		return "", 0, sql.ErrNoRows
+	}
+
+	values := make([]sql.RawBytes, len(columns))
+	scanArgs := make([]interface{}, len(values))
+	for i := range values {
+		scanArgs[i] = &values[i]
+	}
+	if err := rows.Scan(scanArgs...); err != nil {
+		return "", 0, err
+	}
+
+	return binlogPosition(stmt, columns, values)
+}
+
+// binlogPosition picks File and Position out of one row of stmt's result,
+// whatever other columns the server added around them.
+func binlogPosition(stmt string, columns []string, values []sql.RawBytes) (string, uint32, error) {
+	var (
+		file     string
+		pos      uint64
+		foundPos bool
+	)
+	for i, name := range columns {
+		switch strings.ToLower(name) {
+		case "file":
+			file = string(values[i])
+		case "position":
+			var err error
+			pos, err = strconv.ParseUint(string(values[i]), 10, 32)
+			if err != nil {
+				return "", 0, fmt.Errorf("invalid binlog position %q: %v", values[i], err)
+			}
+			foundPos = true
+		}
+	}
+	if file == "" || !foundPos {
+		return "", 0, fmt.Errorf("%s returned no File/Position columns", stmt)
+	}
+
+	return file, uint32(pos), nil
+}
+
+// readOnlyVariable names the session variable that makes transactions
+// read-only. transaction_read_only arrived in MySQL 5.7.20 and MariaDB 11.1;
+// MySQL 8.0.3 removed the older tx_read_only.
+func readOnlyVariable(v serverVersion) string {
+	if (v.mariaDB && !v.atLeast(11, 1, 0)) || (!v.mariaDB && !v.atLeast(5, 7, 20)) {
+		return "tx_read_only"
+	}
+	return "transaction_read_only"
+}
+
+// replicaStatusStatement picks the statement describing the server's own
+// replication. SHOW REPLICA STATUS arrived in MySQL 8.0.22 and MariaDB 10.5.1.
+func replicaStatusStatement(v serverVersion) string {
+	if (v.mariaDB && v.atLeast(10, 5, 1)) || (!v.mariaDB && v.atLeast(8, 0, 22)) {
+		return "SHOW REPLICA STATUS"
+	}
+	return "SHOW SLAVE STATUS"
+}
+
+// replicaRole reports whether the server is itself replicating from a
+// primary and, if so, whether it writes the changes it applies to its own
+// binlog (log_replica_updates, formerly log_slave_updates).
+func replicaRole(db *sql.DB, v serverVersion) (isReplica, logsUpdates bool, err error) {
+	rows, err := db.Query(replicaStatusStatement(v))
+	if err != nil {
+		return false, false, err
+	}
+	isReplica = rows.Next()
+	rows.Close()
+	if err := rows.Err(); err != nil || !isReplica {
+		return false, false, err
+	}
+
+	vars, err := db.Query("SHOW VARIABLES LIKE 'log\\_%\\_updates'")
+	if err != nil {
+		return true, false, err
+	}
+	defer vars.Close()
+	for vars.Next() {
+		var name, value string
+		if err := vars.Scan(&name, &value); err != nil {
+			return true, false, err
+		}
+		if strings.EqualFold(value, "ON") || value == "1" {
+			logsUpdates = true
+		}
+	}
+	return true, logsUpdates, vars.Err()
+}
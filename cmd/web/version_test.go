@@ -0,0 +1,143 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestParseServerVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		want    serverVersion
+		stmt    string
+	}{
+		{
+			name:    "MySQL 5.7",
+			version: "5.7.44-log",
+			want:    serverVersion{major: 5, minor: 7, patch: 44},
+			stmt:    "SHOW MASTER STATUS",
+		},
+		{
+			name:    "MySQL 8.0",
+			version: "8.0.36",
+			want:    serverVersion{major: 8, minor: 0, patch: 36},
+			stmt:    "SHOW MASTER STATUS",
+		},
+		{
+			name:    "MySQL 8.2",
+			version: "8.2.0",
+			want:    serverVersion{major: 8, minor: 2},
+			stmt:    "SHOW BINARY LOG STATUS",
+		},
+		{
+			name:    "MySQL 8.4",
+			version: "8.4.0-commercial",
+			want:    serverVersion{major: 8, minor: 4},
+			stmt:    "SHOW BINARY LOG STATUS",
+		},
+		{
+			name:    "MySQL 9",
+			version: "9.1.0",
+			want:    serverVersion{major: 9, minor: 1},
+			stmt:    "SHOW BINARY LOG STATUS",
+		},
+		{
+			name:    "MariaDB 10.11",
+			version: "10.11.6-MariaDB-1:10.11.6+maria~ubu2204",
+			want:    serverVersion{major: 10, minor: 11, patch: 6, mariaDB: true},
+			stmt:    "SHOW MASTER STATUS",
+		},
+		{
+			name:    "MariaDB 11.4",
+			version: "11.4.2-MariaDB",
+			want:    serverVersion{major: 11, minor: 4, patch: 2, mariaDB: true},
+			stmt:    "SHOW MASTER STATUS",
+		},
+		{
+			name:    "Unparseable",
+			version: "unknown",
+			want:    serverVersion{},
+			stmt:    "SHOW MASTER STATUS",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseServerVersion(tt.version)
+			if got != tt.want {
+				t.Errorf("parseServerVersion(%q) = %v; want %v", tt.version, got, tt.want)
+			}
+			if stmt := binlogStatusStatement(got); stmt != tt.stmt {
+				t.Errorf("binlogStatusStatement(%v) = %q; want %q", got, stmt, tt.stmt)
+			}
+		})
+	}
+}
+
+func TestBinlogPosition(t *testing.T) {
+	tests := []struct {
+		name    string
+		columns []string
+		values  []string
+		file    string
+		pos     uint32
+		wantErr bool
+	}{
+		{
+			name:    "Four columns",
+			columns: []string{"File", "Position", "Binlog_Do_DB", "Binlog_Ignore_DB"},
+			values:  []string{"mysql-bin.000003", "154", "", ""},
+			file:    "mysql-bin.000003",
+			pos:     154,
+		},
+		{
+			name:    "Five columns",
+			columns: []string{"File", "Position", "Binlog_Do_DB", "Binlog_Ignore_DB", "Executed_Gtid_Set"},
+			values:  []string{"binlog.000012", "4567", "", "", "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5"},
+			file:    "binlog.000012",
+			pos:     4567,
+		},
+		{
+			name:    "Six columns in another order",
+			columns: []string{"Server_Id", "Binlog_Do_DB", "Binlog_Ignore_DB", "Executed_Gtid_Set", "position", "file"},
+			values:  []string{"1", "", "", "", "4294967295", "binlog.000001"},
+			file:    "binlog.000001",
+			pos:     4294967295,
+		},
+		{
+			name:    "Missing Position",
+			columns: []string{"File", "Binlog_Do_DB", "Binlog_Ignore_DB", "Executed_Gtid_Set"},
+			values:  []string{"binlog.000001", "", "", ""},
+			wantErr: true,
+		},
+		{
+			name:    "Position out of range",
+			columns: []string{"File", "Position", "Binlog_Do_DB", "Binlog_Ignore_DB"},
+			values:  []string{"binlog.000001", "4294967296", "", ""},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values := make([]sql.RawBytes, len(tt.values))
+			for i, v := range tt.values {
+				values[i] = sql.RawBytes(v)
+			}
+			file, pos, err := binlogPosition("SHOW BINARY LOG STATUS", tt.columns, values)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("binlogPosition = %q, %d; want an error", file, pos)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if file != tt.file || pos != tt.pos {
+				t.Errorf("binlogPosition = %q, %d; want %q, %d", file, pos, tt.file, tt.pos)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"strings"
+)
+
+// watchFilter decides which binlog events get reported. An empty filter
+// watches everything.
+type watchFilter struct {
+	databases map[string]bool
+	tables    []string
+}
+
+func newWatchFilter(databases, tables string) *watchFilter {
+	f := &watchFilter{databases: make(map[string]bool)}
+	for _, db := range splitList(databases) {
+		f.databases[strings.ToLower(db)] = true
+	}
+	for _, t := range splitList(tables) {
+		f.tables = append(f.tables, strings.ToLower(t))
+	}
+	return f
+}
+
+func (f *watchFilter) empty() bool {
+	return len(f.databases) == 0 && len(f.tables) == 0
+}
+
+// allowTable reports whether events for db.table should be broadcast.
+func (f *watchFilter) allowTable(db, table string) bool {
+	if f.empty() {
+		return true
+	}
+	db, table = strings.ToLower(db), strings.ToLower(table)
+	if f.databases[db] {
+		return true
+	}
+	for _, pattern := range f.tables {
+		if pattern == db+".*" || pattern == db+"."+table {
+			return true
+		}
+	}
+	return false
+}
+
+// allowDatabase reports whether events that only carry a schema, such as
+// query events, should be broadcast.
+func (f *watchFilter) allowDatabase(db string) bool {
+	if f.empty() {
+		return true
+	}
+	db = strings.ToLower(db)
+	if f.databases[db] {
+		return true
+	}
+	for _, pattern := range f.tables {
+		if strings.HasPrefix(pattern, db+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// splitList splits a comma-separated flag value, dropping empty items.
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
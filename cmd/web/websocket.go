@@ -3,60 +3,109 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"net"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-mysql-org/go-mysql/mysql"
 	"github.com/go-mysql-org/go-mysql/replication"
 	mysqlDriver "github.com/go-sql-driver/mysql"
+	"github.com/gorilla/websocket"
+
+	"sequelscope.jonnevuorela.com/types"
+)
+
+// binlogDisabled as -binlog-connection turns replication off, leaving a
+// read-only browser.
+const binlogDisabled = "none"
+
+// Delays between retries after the binlog stream fails.
+const (
+	binlogRetryMin = time.Second
+	binlogRetryMax = time.Minute
 )
 
 func (app *application) setupBinlogWatcher() {
-	dsn, err := mysqlDriver.ParseDSN(app.dsn)
+	if app.binlogSource == nil {
+		msg := "replication disabled: no binlog connection configured"
+		app.infoLog.Print(msg)
+		app.watcherStatus.set(watcherDisabled, msg)
+		return
+	}
+
+	dsn, err := mysqlDriver.ParseDSN(app.binlogSource.dsn)
+	if err != nil {
+		app.watcherFailed("error parsing DSN: %v", err)
+		return
+	}
+
+	host, port, err := binlogAddr(dsn)
 	if err != nil {
-		app.errorLog.Printf("error parsing DSN: %v", err)
+		app.watcherFailed("%v", err)
 		return
 	}
 
-	testDb, err := sql.Open("mysql", app.dsn)
+	testDb, err := sql.Open("mysql", app.binlogSource.dsn)
 	if err != nil {
-		app.errorLog.Printf("Test connection failed: %v", err)
+		app.watcherFailed("Test connection failed: %v", err)
 		return
 	}
 	defer testDb.Close()
 
-	err = testDb.Ping()
+	err = pingWithRetry(testDb, app.binlogSource.name+" (binlog)", app.config.dbConnectTimeout, app.infoLog)
 	if err != nil {
-		app.errorLog.Printf("Test ping failed: %v", err)
-		return
-	}
-	var (
-		file            string
-		pos             uint32
-		binlogDoDB      sql.NullString
-		binlogIgnoreDB  sql.NullString
-		executedGtidSet sql.NullString
-	)
-	err = testDb.QueryRow("SHOW MASTER STATUS").Scan(
-		&file,
-		&pos,
-		&binlogDoDB,
-		&binlogIgnoreDB,
-		&executedGtidSet,
-	)
+		app.watcherFailed("Test ping failed: %v", err)
+		return
+	}
+
+	if err := checkReplicationGrants(testDb); err != nil {
+		app.watcherFailed("%v", err)
+		return
+	}
+
+	version, err := queryServerVersion(testDb)
 	if err != nil {
-		app.errorLog.Printf("Direct SHOW MASTER STATUS failed: %v", err)
+		app.watcherFailed("Querying server version failed: %v", err)
 		return
 	}
 
-	addr := strings.SplitAfter(dsn.Addr, ":")
-	port, err := strconv.ParseUint(addr[1], 10, 16)
+	// A replica's own binlog only holds the changes it replays when
+	// log_replica_updates is on; otherwise there is nothing to follow.
+	isReplica, logsUpdates, err := replicaRole(testDb, version)
+	switch {
+	case err != nil:
+		app.errorLog.Printf("Checking replica status failed, assuming a primary: %v", err)
+	case isReplica && !logsUpdates:
+		msg := "connected to a replica without log_replica_updates, so its binlog has no replicated changes; " +
+			"point -binlog-connection at the primary or enable log_replica_updates"
+		app.errorLog.Print(msg)
+		app.watcherStatus.set(watcherDisabled, msg)
+		return
+	case isReplica:
+		app.infoLog.Print("Connected to a replica; following its own binlog, which includes replicated changes")
+	}
+
+	statusStmt := binlogStatusStatement(version)
+	file, pos, err := queryBinlogPosition(testDb, statusStmt)
+	if errors.Is(err, sql.ErrNoRows) {
+		msg := "binary logging appears disabled; real-time updates unavailable"
+		app.errorLog.Print(msg)
+		app.watcherStatus.set(watcherDisabled, msg)
+		return
+	}
+	if err != nil {
+		app.watcherFailed("Direct %s failed on server %s: %v", statusStmt, version, err)
+		return
+	}
 
 	syncerConfig := replication.BinlogSyncerConfig{
 		ServerID: 100,
 		Flavor:   "mysql",
-		Host:     addr[0],
-		Port:     uint16(port),
+		Host:     host,
+		Port:     port,
 		User:     dsn.User,
 		Password: dsn.Passwd,
 	}
@@ -65,58 +114,265 @@ func (app *application) setupBinlogWatcher() {
 
 	streamer, err := app.binlogSyncer.StartSync(mysql.Position{Name: file, Pos: pos})
 	if err != nil {
-		app.errorLog.Printf("error starting binlog sync: %v", err)
+		app.watcherFailed("error starting binlog sync: %v", err)
 		return
 	}
 
 	app.binlogStreamer = streamer
+	app.binlogFile = file
+	app.watcherStatus.set(watcherRunning, "")
 	app.infoLog.Printf("Binlog setup complete")
 
+	ctx, cancel := context.WithCancel(context.Background())
+	app.binlogCancel = cancel
+
+	app.binlogDone = make(chan struct{})
 	go func() {
+		defer close(app.binlogDone)
+		// Errors are retried with a growing delay so a broken stream
+		// doesn't spin; the status shows failed until an event arrives.
+		var backoff time.Duration
 		for {
-			ev, err := app.binlogStreamer.GetEvent(context.Background())
+			if ctx.Err() != nil {
+				return
+			}
+			ev, err := app.binlogStreamer.GetEvent(ctx)
 			if err != nil {
-				app.errorLog.Printf("Binlog event error: %v", err)
+				if ctx.Err() != nil {
+					return
+				}
+				backoff = min(max(2*backoff, binlogRetryMin), binlogRetryMax)
+				app.watcherFailed("Binlog event error, retrying in %s: %v", backoff, err)
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(backoff):
+				}
 				continue
 			}
+			if backoff > 0 {
+				backoff = 0
+				app.infoLog.Print("Binlog events are arriving again")
+				app.watcherStatus.set(watcherRunning, "")
+			}
 			switch e := ev.Event.(type) {
 			case *replication.RowsEvent:
-				app.handleRowsEvent(e)
+				app.handleRowsEvent(ev.Header.EventType, e)
 			case *replication.QueryEvent:
-				app.handleQueryEvent(e)
+				app.handleQueryEvent(ev.Header, e)
+			case *replication.RotateEvent:
+				app.binlogFile = string(e.NextLogName)
 			}
 		}
 	}()
 }
 
-func (app *application) handleRowsEvent(e *replication.RowsEvent) {
-	message := map[string]string{
+// stopBinlogWatcher cancels the binlog reader goroutine, waits for it to
+// exit and closes the syncer.
+func (app *application) stopBinlogWatcher() {
+	if app.binlogCancel != nil {
+		app.binlogCancel()
+		<-app.binlogDone
+		app.binlogCancel = nil
+	}
+	if app.binlogSyncer != nil {
+		app.binlogSyncer.Close()
+	}
+}
+
+// watcherFailed logs a binlog setup error and records it as the watcher status.
+func (app *application) watcherFailed(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	app.errorLog.Output(2, msg)
+	app.watcherStatus.set(watcherFailed, msg)
+}
+
+// binlogAddr returns the TCP host and port the binlog syncer should connect
+// to. Replication can't use unix sockets, so socket DSNs are rejected.
+func binlogAddr(dsn *mysqlDriver.Config) (string, uint16, error) {
+	if dsn.Net == "unix" {
+		return "", 0, fmt.Errorf("binlog replication requires a TCP connection but the DSN uses the unix socket %s; use a DSN like user:pass@tcp(127.0.0.1:3306)/db", dsn.Addr)
+	}
+
+	host, portStr, err := net.SplitHostPort(dsn.Addr)
+	if err != nil {
+		// Addresses without a port use the MySQL default.
+		return dsn.Addr, 3306, nil
+	}
+
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid port in DSN address %q: %v", dsn.Addr, err)
+	}
+	return host, uint16(port), nil
+}
+
+func (app *application) handleRowsEvent(eventType replication.EventType, e *replication.RowsEvent) {
+	if !app.databases.allow(string(e.Table.Schema)) || !app.watch.allowTable(string(e.Table.Schema), string(e.Table.Table)) {
+		return
+	}
+
+	app.activity.increment(string(e.Table.Schema), string(e.Table.Table))
+	app.eventRate.increment()
+
+	// Update events carry a before and an after image of each row.
+	action := rowsAction(eventType)
+	changed := len(e.Rows)
+	if action == "update" {
+		changed /= 2
+	}
+	app.deltas.add(string(e.Table.Schema), string(e.Table.Table), action, changed, time.Now())
+
+	// Bulk changes only broadcast their first rows, keeping update pairs
+	// together, and say how many there were.
+	send := len(e.Rows)
+	limit := app.config.maxBroadcastRows
+	truncated := limit > 0 && changed > limit
+	if truncated {
+		send = limit * len(e.Rows) / changed
+	}
+
+	message := map[string]any{
 		"type":     "row_change",
+		"action":   action,
 		"table":    string(e.Table.Table),
 		"database": string(e.Table.Schema),
+		"rows":     app.labelRows(e, send),
 	}
+	if truncated {
+		message["truncated"] = true
+		message["total"] = changed
+	}
+	app.events.add(types.EventSummary{
+		Time:     time.Now(),
+		Type:     "row_change",
+		Database: string(e.Table.Schema),
+		Table:    string(e.Table.Table),
+		Summary:  fmt.Sprintf("%s %d row(s)", action, changed),
+	})
 	app.broadcastChange(message)
 }
 
-func (app *application) handleQueryEvent(e *replication.QueryEvent) {
-	message := map[string]string{
+// isTxnMarker reports whether query is a bare transaction control statement
+// such as the BEGIN that precedes every row-based transaction.
+func isTxnMarker(query string) bool {
+	fields := strings.Fields(strings.TrimSpace(query))
+	if len(fields) == 0 {
+		return false
+	}
+	switch strings.ToUpper(strings.TrimSuffix(fields[0], ";")) {
+	case "BEGIN", "COMMIT", "ROLLBACK", "SAVEPOINT", "RELEASE", "XA":
+		return true
+	}
+	return false
+}
+
+func (app *application) handleQueryEvent(header *replication.EventHeader, e *replication.QueryEvent) {
+	if !app.config.includeTxnMarkers && isTxnMarker(string(e.Query)) {
+		return
+	}
+
+	// Schemas outside -databases stay out of the audit log too.
+	if app.audit != nil && app.databases.allow(string(e.Schema)) {
+		err := app.audit.write(auditRecord{
+			Time:     time.Unix(int64(header.Timestamp), 0).UTC(),
+			Schema:   string(e.Schema),
+			Query:    string(e.Query),
+			File:     app.binlogFile,
+			Position: header.LogPos,
+		})
+		if err != nil {
+			app.errorLog.Printf("Writing audit log failed: %v", err)
+		}
+	}
+
+	// Cached schema goes stale whether or not the event is broadcast.
+	ddl, isDDL := parseDDL(string(e.Query))
+	if isDDL {
+		app.metadata.invalidate()
+		app.rowColumns.reset()
+	}
+	if isDDL && ddl.kind == "database" && (ddl.action == "CREATE" || ddl.action == "DROP") {
+		if err := app.getDatabases(); err != nil {
+			app.errorLog.Printf("Refreshing databases failed: %v", err)
+		}
+	}
+
+	if !app.databases.allow(string(e.Schema)) || !app.watch.allowDatabase(string(e.Schema)) {
+		return
+	}
+	app.eventRate.increment()
+
+	// Migrations and LOAD DATA statements can be huge; only the audit log
+	// keeps them whole.
+	query, truncated := truncateCell(string(e.Query), app.config.maxQueryBytes)
+	message := map[string]any{
 		"type":     "query",
 		"database": string(e.Schema),
-		"query":    string(e.Query),
+		"query":    query,
 	}
+	if truncated {
+		message["truncated"] = true
+	}
+	if isDDL {
+		message["type"] = "ddl"
+		message["action"] = ddl.action
+		message["kind"] = ddl.kind
+		message["object"] = ddl.object
+	}
+
+	if app.config.explainQueries && isReadStatement(string(e.Query)) {
+		ctx, cancel := context.WithTimeout(context.Background(), explainTimeout)
+		defer cancel()
+
+		plan, err := app.binlogSource.explain(ctx, string(e.Schema), string(e.Query))
+		if err != nil {
+			app.errorLog.Printf("EXPLAIN failed: %v", err)
+		} else {
+			message["plan"] = plan
+		}
+	}
+
+	app.events.add(types.EventSummary{
+		Time:     time.Now(),
+		Type:     message["type"].(string),
+		Database: string(e.Schema),
+		Summary:  query,
+	})
 	app.broadcastChange(message)
 }
 
-func (app *application) broadcastChange(message map[string]string) {
-	app.clientsMux.RLock()
-	defer app.clientsMux.RUnlock()
+func (app *application) broadcastChange(message map[string]any) {
+	event := isEventMessage(message)
+	if event && app.paused.Load() {
+		return
+	}
 
-	for client := range app.clients {
-		err := client.WriteJSON(message)
+	// Failed clients are only collected here; deleting from the map needs
+	// the write lock.
+	var failed []*websocket.Conn
+
+	app.clientsMux.RLock()
+	for client, state := range app.clients {
+		if event && (state.paused.Load() || !state.wants(message)) {
+			continue
+		}
+		err := state.writeJSON(client, message, app.config.wsWriteTimeout)
 		if err != nil {
 			app.errorLog.Printf("Error broadcasting to client: %v", err)
-			client.Close()
-			delete(app.clients, client)
+			failed = append(failed, client)
 		}
 	}
+	app.clientsMux.RUnlock()
+
+	if len(failed) == 0 {
+		return
+	}
+
+	app.clientsMux.Lock()
+	for _, client := range failed {
+		client.Close()
+		delete(app.clients, client)
+	}
+	app.clientsMux.Unlock()
 }
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"sequelscope.jonnevuorela.com/types"
+)
+
+// BookmarkModel stores bookmarked tables. Bookmarks are global; the
+// user_id column is left NULL until there are logged-in users.
+type BookmarkModel struct {
+	DB *sql.DB
+}
+
+// Insert bookmarks a table. Bookmarking it again is a no-op.
+func (m *BookmarkModel) Insert(connection, database, table string) error {
+	stmt := `INSERT OR IGNORE INTO bookmarks (connection, database_name, table_name, created)
+	VALUES (?, ?, ?, ?)`
+
+	_, err := m.DB.Exec(stmt, connection, database, table, time.Now().UTC())
+	return err
+}
+
+func (m *BookmarkModel) Delete(connection, database, table string) error {
+	stmt := `DELETE FROM bookmarks
+	WHERE user_id IS NULL AND connection = ? AND database_name = ? AND table_name = ?`
+
+	_, err := m.DB.Exec(stmt, connection, database, table)
+	return err
+}
+
+func (m *BookmarkModel) Exists(connection, database, table string) (bool, error) {
+	stmt := `SELECT EXISTS(SELECT 1 FROM bookmarks
+	WHERE user_id IS NULL AND connection = ? AND database_name = ? AND table_name = ?)`
+
+	var exists bool
+	err := m.DB.QueryRow(stmt, connection, database, table).Scan(&exists)
+	return exists, err
+}
+
+// All returns every bookmark, ordered by database and table.
+func (m *BookmarkModel) All() ([]types.Bookmark, error) {
+	stmt := `SELECT id, connection, database_name, table_name, created FROM bookmarks
+	WHERE user_id IS NULL ORDER BY connection, database_name, table_name`
+
+	rows, err := m.DB.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var bookmarks []types.Bookmark
+	for rows.Next() {
+		var b types.Bookmark
+		if err := rows.Scan(&b.Id, &b.Connection, &b.Database, &b.Table, &b.Created); err != nil {
+			return nil, err
+		}
+		bookmarks = append(bookmarks, b)
+	}
+	return bookmarks, rows.Err()
+}
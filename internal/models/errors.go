@@ -0,0 +1,5 @@
+package models
+
+import "errors"
+
+var ErrNoRecord = errors.New("models: no matching record found")
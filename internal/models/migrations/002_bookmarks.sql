@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS bookmarks (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    connection TEXT NOT NULL,
+    database_name TEXT NOT NULL,
+    table_name TEXT NOT NULL,
+    created DATETIME NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS bookmarks_unique
+    ON bookmarks (IFNULL(user_id, 0), connection, database_name, table_name);
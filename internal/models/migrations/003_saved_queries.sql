@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS saved_queries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    connection TEXT NOT NULL,
+    database_name TEXT NOT NULL,
+    sql_text TEXT NOT NULL,
+    created DATETIME NOT NULL
+);
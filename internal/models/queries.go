@@ -0,0 +1,68 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"sequelscope.jonnevuorela.com/types"
+)
+
+// QueryModel stores named console queries. Like bookmarks they are global
+// until there are logged-in users to own them.
+type QueryModel struct {
+	DB *sql.DB
+}
+
+// SaveQuery stores a query and returns its id.
+func (m *QueryModel) SaveQuery(name, connection, database, sqlText string) (int, error) {
+	stmt := `INSERT INTO saved_queries (name, connection, database_name, sql_text, created)
+	VALUES (?, ?, ?, ?, ?)`
+
+	result, err := m.DB.Exec(stmt, name, connection, database, sqlText, time.Now().UTC())
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+func (m *QueryModel) Get(id int) (*types.SavedQuery, error) {
+	stmt := `SELECT id, name, connection, database_name, sql_text, created
+	FROM saved_queries WHERE id = ?`
+
+	q := &types.SavedQuery{}
+	err := m.DB.QueryRow(stmt, id).Scan(&q.Id, &q.Name, &q.Connection, &q.Database, &q.SQL, &q.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+	return q, nil
+}
+
+// ListQueries returns the saved queries ordered by name.
+func (m *QueryModel) ListQueries() ([]types.SavedQuery, error) {
+	stmt := `SELECT id, name, connection, database_name, sql_text, created
+	FROM saved_queries WHERE user_id IS NULL ORDER BY name, id`
+
+	rows, err := m.DB.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var queries []types.SavedQuery
+	for rows.Next() {
+		var q types.SavedQuery
+		if err := rows.Scan(&q.Id, &q.Name, &q.Connection, &q.Database, &q.SQL, &q.Created); err != nil {
+			return nil, err
+		}
+		queries = append(queries, q)
+	}
+	return queries, rows.Err()
+}
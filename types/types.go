@@ -6,10 +6,73 @@ import (
 )
 
 type TemplateData struct {
-	CurrentYear int
-	Entry       *Entry
-	Entries     []*Entry
-	TableData   *TableData
+	CurrentYear  int
+	CurrentPath  string
+	BasePath     string
+	Density      string
+	Flash        string
+	Entry        *Entry
+	Entries      []*Entry
+	Sizes        map[int][]SizeSample
+	Connections  []string
+	TableData    *TableData
+	Row          []RowField
+	RowKey       string
+	Diff         *SchemaDiff
+	Query        *QueryConsole
+	Events       []EventSummary
+	RecentEvents []EventSummary
+	Profile      *ColumnProfile
+	Clients      int
+	ClientList   []ClientInfo
+	Bookmarks    []Bookmark
+	Breadcrumbs  []Crumb
+	Bookmarked   bool
+	Filter       string
+	Routines     []Routine
+	Triggers     []Trigger
+	Routine      *Routine
+	Activity     []TableActivity
+	Watcher      WatcherStatus
+}
+
+// SizeSample is the data plus index size of a database at one point in time.
+type SizeSample struct {
+	Time  time.Time
+	Bytes int64
+}
+
+// Routine is a stored procedure or function. Created and Modified are kept
+// as the server's text. Definition is only loaded for the routine page.
+type Routine struct {
+	Name       string
+	Type       string
+	Definer    string
+	Created    string
+	Modified   string
+	Comment    string
+	Definition string
+}
+
+type Trigger struct {
+	Name      string
+	Timing    string
+	Event     string
+	Table     string
+	Statement string
+}
+
+// DatabaseSummary is one database in the JSON form of the home page.
+type DatabaseSummary struct {
+	Id         int    `json:"id"`
+	Title      string `json:"title"`
+	Connection string `json:"connection"`
+	Tables     int    `json:"tables"`
+}
+
+type WatcherStatus struct {
+	State   string `json:"state"`
+	Message string `json:"message,omitempty"`
 }
 
 type Column struct {
@@ -22,32 +85,233 @@ type Column struct {
 }
 
 type Table struct {
-	TableName   string
-	Columns     []Column
-	EntryCount  int
-	LatestEntry LatestRow
+	TableName         string
+	Type              string
+	Columns           []Column
+	AutoIncrement     uint64
+	AutoIncrementPct  float64
+	AutoIncrementWarn bool
+	UpdateTime        time.Time
+}
+
+type QueryConsole struct {
+	Connection string
+	Database   string
+	SQL        string
+	Analyze    bool
+	Result     *TableData
+	Plan       string
+	Note       string
+	Error      string
+	Saved      []SavedQuery
+}
+
+type Index struct {
+	Name        string   `json:"name"`
+	Columns     []string `json:"columns"`
+	Unique      bool     `json:"unique"`
+	Cardinality int64    `json:"cardinality"`
 }
 
 type TableData struct {
-	Columns []string
-	Rows    []map[string]string
+	Connection      string              `json:"connection,omitempty"`
+	Database        string              `json:"database,omitempty"`
+	Table           string              `json:"table,omitempty"`
+	Columns         []string            `json:"columns"`
+	AllColumns      []string            `json:"-"`
+	SelectedColumns string              `json:"-"`
+	EnumOptions     map[string][]string `json:"enumOptions,omitempty"`
+	FilterColumn    string              `json:"filterColumn,omitempty"`
+	FilterValue     string              `json:"filterValue,omitempty"`
+	ColumnTypes     []string            `json:"columnTypes"`
+	Rows            []map[string]string `json:"rows"`
+	PrimaryKey      []string            `json:"primaryKey,omitempty"`
+	RowKeys         []string            `json:"rowKeys,omitempty"`
+	NextCursor      string              `json:"next,omitempty"`
+	TotalRows       int                 `json:"totalRows"`
+	TotalApprox     bool                `json:"totalApprox"`
+	DisplayMode     string              `json:"-"`
+	DisplayColumn   string              `json:"-"`
+	Linkify         bool                `json:"-"`
+	InvalidUTF8     map[string]bool     `json:"invalidUtf8,omitempty"`
+	Truncated       []map[string]bool   `json:"truncated,omitempty"`
+	TimeZone        string              `json:"timeZone,omitempty"`
+	Limit           int                 `json:"limit,omitempty"`
+	Statement       string              `json:"statement,omitempty"`
+	Plan            []map[string]string `json:"plan,omitempty"`
+	FullScan        bool                `json:"fullScan,omitempty"`
+}
+
+type RowField struct {
+	Name  string
+	Value string
+	Null  bool
 }
 
 type Entry struct {
-	Id      int
-	Title   string
-	Tables  []Table
-	Created time.Time
+	Id         int
+	Title      string
+	Connection string
+	Tables     []Table
+	Created    time.Time
+}
+
+type SchemaDiff struct {
+	A       string
+	B       string
+	OnlyInA []string
+	OnlyInB []string
+	Tables  []TableDiff
+}
+
+type TableDiff struct {
+	TableName string
+	Columns   []ColumnDiff
+}
+
+type ColumnDiff struct {
+	Field string
+	A     Column
+	B     Column
+	InA   bool
+	InB   bool
+}
+
+// ClientInfo describes one connected websocket client.
+type ClientInfo struct {
+	RemoteAddr string
+	Connected  time.Time
+	Database   string
+	Table      string
+	Paused     bool
+	Sent       int64
+}
+
+type TableActivity struct {
+	Database string
+	Table    string
+	Count    int
+}
+
+// TableDeltas counts the rows changed in one table, as seen in the binlog.
+type TableDeltas struct {
+	Database string `json:"database"`
+	Table    string `json:"table"`
+	Inserts  int    `json:"inserts"`
+	Updates  int    `json:"updates"`
+	Deletes  int    `json:"deletes"`
+}
+
+// DeltaReport is the /api/deltas response.
+type DeltaReport struct {
+	Database string        `json:"database,omitempty"`
+	Since    time.Time     `json:"since"`
+	Tables   []TableDeltas `json:"tables"`
+}
+
+type EventSummary struct {
+	Time     time.Time
+	Type     string
+	Database string
+	Table    string
+	Summary  string
+}
+
+// TableMeta is the per-table detail loaded when a table is expanded in the
+// database view.
+type TableMeta struct {
+	Connection       string         `json:"connection"`
+	Database         string         `json:"database"`
+	Table            string         `json:"table"`
+	Type             string         `json:"type"`
+	EntryCount       int            `json:"entryCount"`
+	EntryCountApprox bool           `json:"entryCountApprox"`
+	Columns          []SchemaColumn `json:"columns"`
+	Indexes          []Index        `json:"indexes"`
+}
+
+// SchemaDocument is the machine-readable schema of one database.
+type SchemaDocument struct {
+	Connection  string        `json:"connection"`
+	Database    string        `json:"database"`
+	GeneratedAt time.Time     `json:"generatedAt"`
+	Tables      []SchemaTable `json:"tables"`
+}
+
+type SchemaTable struct {
+	Name        string         `json:"name"`
+	Type        string         `json:"type"`
+	Columns     []SchemaColumn `json:"columns"`
+	Indexes     []SchemaIndex  `json:"indexes"`
+	ForeignKeys []ForeignKey   `json:"foreignKeys"`
+}
+
+type SchemaColumn struct {
+	Field   string   `json:"field"`
+	Type    string   `json:"type"`
+	Null    bool     `json:"null"`
+	Key     string   `json:"key,omitempty"`
+	Default *string  `json:"default"`
+	Extra   string   `json:"extra,omitempty"`
+	Values  []string `json:"values,omitempty"`
+}
+
+type SchemaIndex struct {
+	Name    string   `json:"name"`
+	Columns []string `json:"columns"`
+	Unique  bool     `json:"unique"`
+}
+
+type ForeignKey struct {
+	Name              string   `json:"name"`
+	Columns           []string `json:"columns"`
+	ReferencedTable   string   `json:"referencedTable"`
+	ReferencedColumns []string `json:"referencedColumns"`
+}
+
+// ColumnProfile summarizes the values of one column.
+type ColumnProfile struct {
+	Connection string
+	Database   string
+	Table      string
+	Column     string
+	Type       string
+	Rows       int64
+	Distinct   int64
+	Nulls      int64
+	Min        string
+	Max        string
+	Redacted   bool
+	Top        []ValueCount
+}
+
+type ValueCount struct {
+	Value string
+	Null  bool
+	Count int64
+}
+
+// Crumb is one step of the breadcrumb trail. Crumbs without a URL, such as
+// a database that isn't listed, render as plain text; the table crumb keeps
+// its URL so row and column pages can link back to the table.
+type Crumb struct {
+	Label string
+	URL   string
 }
 
-type User struct {
-	Id      int
-	Name    string
-	Email   string
-	Created time.Time
+type Bookmark struct {
+	Id         int
+	Connection string
+	Database   string
+	Table      string
+	Created    time.Time
 }
 
-type LatestRow struct {
-	Id    int
-	Title string
+type SavedQuery struct {
+	Id         int
+	Name       string
+	Connection string
+	Database   string
+	SQL        string
+	Created    time.Time
 }
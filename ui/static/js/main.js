@@ -6,3 +6,178 @@ for (var i = 0; i < navLinks.length; i++) {
       break;
    }
 }
+
+// Quick-jump palette: press "/" or Ctrl+K to search databases and tables
+(function () {
+   var palette = document.createElement("div");
+   palette.className = "palette";
+   palette.hidden = true;
+   palette.innerHTML = '<input type="text" placeholder="Jump to database or table..."><ul></ul>';
+   document.body.appendChild(palette);
+
+   var input = palette.querySelector("input");
+   var list = palette.querySelector("ul");
+   var results = [];
+   var selected = 0;
+
+   function render() {
+      list.innerHTML = "";
+      results.forEach(function (r, i) {
+         var li = document.createElement("li");
+         var a = document.createElement("a");
+         a.href = r.url;
+         a.textContent = r.kind === "table" ? r.database + "." + r.table : r.database;
+         li.appendChild(a);
+         if (i === selected) {
+            li.className = "selected";
+         }
+         list.appendChild(li);
+      });
+   }
+
+   function open() {
+      palette.hidden = false;
+      input.value = "";
+      results = [];
+      render();
+      input.focus();
+   }
+
+   function close() {
+      palette.hidden = true;
+   }
+
+   var timer;
+   input.addEventListener("input", function () {
+      clearTimeout(timer);
+      timer = setTimeout(function () {
+         fetch(basePath() + "/search?q=" + encodeURIComponent(input.value))
+            .then(function (response) { return response.json(); })
+            .then(function (data) {
+               results = data;
+               selected = 0;
+               render();
+            });
+      }, 150);
+   });
+
+   input.addEventListener("keydown", function (e) {
+      if (e.key === "ArrowDown") {
+         selected = Math.min(selected + 1, results.length - 1);
+         render();
+         e.preventDefault();
+      } else if (e.key === "ArrowUp") {
+         selected = Math.max(selected - 1, 0);
+         render();
+         e.preventDefault();
+      } else if (e.key === "Enter" && results[selected]) {
+         window.location.href = results[selected].url;
+      } else if (e.key === "Escape") {
+         close();
+      }
+   });
+
+   document.addEventListener("keydown", function (e) {
+      var typing = e.target.tagName === "INPUT" || e.target.tagName === "TEXTAREA";
+      if ((e.key === "/" && !typing) || (e.key === "k" && (e.ctrlKey || e.metaKey))) {
+         e.preventDefault();
+         open();
+      }
+   });
+})();
+
+// Infinite scroll: fetch the rows after the last primary key, rendered by the
+// server like the first page, and append them
+var loadMore = document.querySelector("button.load-more");
+if (loadMore) {
+   loadMore.addEventListener("click", function () {
+      var url = loadMore.dataset.url + "&from=" + encodeURIComponent(loadMore.dataset.next);
+      fetch(url)
+         .then(function (response) {
+            if (!response.ok) {
+               return;
+            }
+            return response.text().then(function (rows) {
+               var tbody = loadMore.closest(".text-content").querySelector(".db-table tbody");
+               tbody.insertAdjacentHTML("beforeend", rows);
+               var next = response.headers.get("X-Next-Cursor");
+               if (next) {
+                  loadMore.dataset.next = decodeURIComponent(next);
+               } else {
+                  loadMore.remove();
+               }
+            });
+         });
+   });
+}
+
+// Load a saved query as soon as it is picked
+var savedQueries = document.querySelector("select#saved");
+if (savedQueries) {
+   savedQueries.addEventListener("change", function () {
+      if (savedQueries.value) {
+         savedQueries.form.submit();
+      }
+   });
+}
+
+// Database view: load a table's columns, count and indexes when expanded
+function metaTable(headers, rows) {
+   var table = document.createElement("table");
+   table.className = "db-table";
+   var head = table.createTHead().insertRow();
+   headers.forEach(function (h) {
+      var th = document.createElement("th");
+      th.textContent = h;
+      head.appendChild(th);
+   });
+   var body = table.createTBody();
+   rows.forEach(function (cells) {
+      var tr = body.insertRow();
+      cells.forEach(function (c) {
+         tr.insertCell().textContent = c;
+      });
+   });
+   return table;
+}
+
+document.querySelectorAll("details.table-meta").forEach(function (details) {
+   details.addEventListener("toggle", function () {
+      if (!details.open || details.dataset.loaded) {
+         return;
+      }
+      details.dataset.loaded = "1";
+      var body = details.querySelector(".table-meta-body");
+      var params = new URLSearchParams({
+         db: details.dataset.db,
+         table: details.dataset.table,
+         conn: details.dataset.conn
+      });
+      fetch(basePath() + "/api/table-meta?" + params.toString())
+         .then(function (response) {
+            if (!response.ok) {
+               throw new Error(response.statusText);
+            }
+            return response.json();
+         })
+         .then(function (meta) {
+            body.textContent = "";
+            var summary = document.createElement("p");
+            summary.textContent = "(" + (meta.entryCountApprox ? "~" : "") +
+               meta.entryCount.toLocaleString() + " entries)";
+            body.appendChild(summary);
+            body.appendChild(metaTable(["Column", "Type", "Null", "Key", "Values"], meta.columns.map(function (c) {
+               return [c.field, c.type, c.null ? "YES" : "NO", c.key || "", (c.values || []).join(", ")];
+            })));
+            if (meta.indexes.length) {
+               body.appendChild(metaTable(["Index", "Columns", "Unique", "Cardinality"], meta.indexes.map(function (i) {
+                  return [i.name, i.columns.join(", "), i.unique ? "yes" : "no", i.cardinality.toLocaleString()];
+               })));
+            }
+         })
+         .catch(function (err) {
+            delete details.dataset.loaded;
+            body.textContent = "Could not load table details: " + err.message;
+         });
+   });
+});
@@ -0,0 +1,98 @@
+// Live table watch: subscribe to one table's events and patch the grid in
+// place instead of reloading the page.
+(function () {
+   var root = document.getElementById("table-watch");
+   if (!root) {
+      return;
+   }
+   var db = root.dataset.db;
+   var table = root.dataset.table;
+   // Primary key columns, comma separated. Composite keys are matched by
+   // the JSON array of their values, the same encoding the server uses.
+   var pk = root.dataset.pk ? root.dataset.pk.split(",") : [];
+   var tbody = root.querySelector(".db-table tbody");
+   var columns = Array.prototype.map.call(root.querySelectorAll(".db-table thead th"), function (th) {
+      return th.firstChild.textContent.trim();
+   });
+
+   // Values in row payloads are keyed by table.column
+   function value(row, col) {
+      var v = row[table + "." + col];
+      return v === null || v === undefined ? "NULL" : String(v);
+   }
+
+   function rowKey(row) {
+      var values = pk.map(function (col) { return value(row, col); });
+      return values.length === 1 ? values[0] : JSON.stringify(values);
+   }
+
+   function findRow(row) {
+      if (!pk.length) {
+         return null;
+      }
+      return tbody.querySelector('tr[data-pk="' + CSS.escape(rowKey(row)) + '"]');
+   }
+
+   function fill(tr, row) {
+      tr.innerHTML = "";
+      columns.forEach(function (col) {
+         var td = document.createElement("td");
+         td.textContent = value(row, col);
+         td.title = td.textContent;
+         tr.appendChild(td);
+      });
+      if (pk.length) {
+         tr.dataset.pk = rowKey(row);
+      }
+   }
+
+   function flash(tr, cls) {
+      tr.classList.remove("changed", "inserted");
+      void tr.offsetWidth;
+      tr.classList.add(cls);
+   }
+
+   window.onLiveOpen = function (ws) {
+      ws.send(JSON.stringify({ type: "subscribe", database: db, table: table }));
+   };
+
+   window.onLiveMessage = function (data) {
+      if (data.type !== "row_change" || data.database !== db || data.table !== table) {
+         return true;
+      }
+      var rows = data.rows || [];
+      if (data.truncated && !root.querySelector(".truncated-notice")) {
+         var notice = document.createElement("p");
+         notice.className = "flash truncated-notice";
+         notice.textContent = "A bulk change of " + data.total + " rows was only partly sent; reload to see every row.";
+         root.prepend(notice);
+      }
+      if (data.action === "insert") {
+         rows.forEach(function (row) {
+            var tr = document.createElement("tr");
+            fill(tr, row);
+            tbody.prepend(tr);
+            flash(tr, "inserted");
+         });
+      } else if (data.action === "update") {
+         // Rows alternate between the before and after images
+         for (var i = 0; i + 1 < rows.length; i += 2) {
+            var tr = findRow(rows[i]);
+            if (!tr) {
+               tr = document.createElement("tr");
+               tbody.prepend(tr);
+            }
+            fill(tr, rows[i + 1]);
+            flash(tr, "changed");
+         }
+      } else if (data.action === "delete") {
+         rows.forEach(function (row) {
+            var tr = findRow(row);
+            if (tr) {
+               tr.classList.add("deleted");
+            }
+         });
+      }
+      return true;
+   };
+})();
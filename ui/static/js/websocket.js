@@ -1,25 +1,71 @@
+// Path prefix the app is mounted under, set on <body> by the server
+function basePath() {
+    return document.body ? document.body.dataset.basePath || '' : '';
+}
+
 function connectWebSocket() {
     let reconnectAttempts = 0;
     const maxReconnectAttempts = 5;
+    // Set when the server says goodbye before a restart; it's expected to
+    // come back, so keep retrying instead of giving up.
+    let serverRestarting = false;
 
     function connect() {
         console.log('Attempting WebSocket connection...');
 
         const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
-        const ws = new WebSocket(`${protocol}//${window.location.host}/ws`);
+        const ws = new WebSocket(`${protocol}//${window.location.host}${basePath()}/ws`);
 
         ws.onopen = function() {
             console.log('WebSocket connection established');
+            window.liveSocket = ws;
+            if (window.onLiveOpen) {
+                window.onLiveOpen(ws);
+            }
+            if (sessionStorage.getItem('paused') === '1') {
+                ws.send(JSON.stringify({ type: 'pause' }));
+            }
             reconnectAttempts = 0; // Reset attempts on successful connection
+            if (serverRestarting) {
+                serverRestarting = false;
+                pollStatus();
+            }
         };
 
         ws.onmessage = function(event) {
             try {
                 const data = JSON.parse(event.data);
+                if (data.type === 'stats') {
+                    showStats(data);
+                    return;
+                }
+                if (data.type === 'bye') {
+                    console.log('Server is going away:', data.reason);
+                    serverRestarting = true;
+                    const indicator = document.getElementById('live-indicator');
+                    if (indicator) {
+                        indicator.textContent = 'restarting';
+                        indicator.classList.remove('online');
+                    }
+                    return;
+                }
                 console.log('Received database change:', data);
 
+                // Pages that update themselves in place handle events first
+                if (window.onLiveMessage && window.onLiveMessage(data)) {
+                    return;
+                }
+
+                // The home page lists changes live instead of reloading
+                if (data.type !== 'ddl' && prependRecentChange(data)) {
+                    return;
+                }
+
                 // notification before reload
-                const message = `${data.type === 'query' ? 'Query executed' : 'Data changed'} in ${data.database}`;
+                let message = `${data.type === 'query' ? 'Query executed' : 'Data changed'} in ${data.database}`;
+                if (data.type === 'ddl') {
+                    message = `Schema change: ${data.action} ${data.kind} ${data.object}`;
+                }
                 console.log(message);
 
                 window.location.reload();
@@ -31,8 +77,11 @@ function connectWebSocket() {
         ws.onclose = function(event) {
             console.log('WebSocket connection closed. Code:', event.code, 'Reason:', event.reason);
 
-            if (reconnectAttempts < maxReconnectAttempts) {
-                const timeout = Math.min(1000 * Math.pow(2, reconnectAttempts), 10000);
+            if (serverRestarting || reconnectAttempts < maxReconnectAttempts) {
+                // Give a restarting server a moment before the first try
+                const timeout = serverRestarting && reconnectAttempts === 0
+                    ? 2000
+                    : Math.min(1000 * Math.pow(2, reconnectAttempts), serverRestarting ? 30000 : 10000);
                 console.log(`Attempting to reconnect in ${timeout/1000} seconds...`);
                 reconnectAttempts++;
                 setTimeout(connect, timeout);
@@ -55,3 +104,103 @@ document.addEventListener('DOMContentLoaded', () => {
     connectWebSocket();
 });
 
+
+// Add an event to the home page's recent changes list, keeping it short.
+// Returns false when the page has no such list.
+function prependRecentChange(data) {
+    const list = document.getElementById('recent-changes');
+    if (!list) {
+        return false;
+    }
+
+    const empty = list.querySelector('.empty');
+    if (empty) {
+        empty.remove();
+    }
+
+    const li = document.createElement('li');
+    const time = document.createElement('time');
+    time.textContent = new Date().toTimeString().slice(0, 8);
+    li.appendChild(time);
+
+    let text = ` ${data.type} ${data.database}`;
+    if (data.table) {
+        text += `.${data.table}`;
+    }
+    if (data.action) {
+        text += ` ${data.action}`;
+    }
+    if (data.query) {
+        text += ` ${data.query.length > 60 ? data.query.slice(0, 60) + '...' : data.query}`;
+    }
+    li.appendChild(document.createTextNode(text));
+
+    list.prepend(li);
+    while (list.children.length > 10) {
+        list.lastElementChild.remove();
+    }
+    return true;
+}
+
+// Show the server's events-per-second rate next to the live indicator
+function showStats(stats) {
+    const el = document.getElementById('eps-indicator');
+    if (!el) {
+        return;
+    }
+    el.textContent = `${stats.eps.toFixed(1)} ev/s`;
+    el.title = `${stats.clients} connected client${stats.clients === 1 ? '' : 's'}`;
+
+    const count = document.getElementById('client-count');
+    if (count) {
+        count.textContent = `${stats.clients} connected`;
+    }
+}
+
+// Pause or resume live updates for this browser tab
+function togglePause() {
+    const button = document.getElementById('pause-toggle');
+    const paused = sessionStorage.getItem('paused') !== '1';
+    sessionStorage.setItem('paused', paused ? '1' : '0');
+    if (window.liveSocket && window.liveSocket.readyState === WebSocket.OPEN) {
+        window.liveSocket.send(JSON.stringify({ type: paused ? 'pause' : 'resume' }));
+    }
+    if (button) {
+        button.textContent = paused ? 'Resume' : 'Pause';
+    }
+}
+
+document.addEventListener('DOMContentLoaded', () => {
+    const button = document.getElementById('pause-toggle');
+    if (!button) {
+        return;
+    }
+    button.textContent = sessionStorage.getItem('paused') === '1' ? 'Resume' : 'Pause';
+    button.addEventListener('click', togglePause);
+});
+
+// Poll the watcher status so the nav shows whether live updates are flowing
+function pollStatus() {
+    const indicator = document.getElementById('live-indicator');
+    if (!indicator) {
+        return;
+    }
+
+    fetch(`${basePath()}/api/status`)
+        .then(response => response.json())
+        .then(status => {
+            const live = status.watcher && status.watcher.state === 'running';
+            indicator.textContent = live ? 'live' : 'offline';
+            indicator.classList.toggle('online', live);
+            indicator.title = status.watcher && status.watcher.message ? status.watcher.message : '';
+        })
+        .catch(() => {
+            indicator.textContent = 'offline';
+            indicator.classList.remove('online');
+        });
+}
+
+document.addEventListener('DOMContentLoaded', () => {
+    pollStatus();
+    setInterval(pollStatus, 10000);
+});